@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/pkgs"
+	"github.com/mmihic/go-tools/pkg/report"
+)
+
+// A generatedFile is a generated file affected by the moves.
+type generatedFile struct {
+	fname  string
+	policy pkgs.GeneratedPolicy
+
+	// The go:generate directives that regenerate the file
+	directives []*pkgs.GenerateDirective
+
+	// The contents of the file before it was regenerated
+	before []byte
+
+	// The report of the file
+	report *report.Generated
+}
+
+// handleGenerated records a generated file affected by the given moves,
+// returning the policy that applies to it and whether to rewrite it along with
+// hand-written files. Files to be regenerated are rewritten too, so that their
+// generators see a fully rewritten tree, and so that they are left rewritten
+// if regenerating them fails.
+func (rw *rewriter) handleGenerated(fname string, file *ast.File, affecting pkgs.Moves) (pkgs.GeneratedPolicy, bool, error) {
+	gf := &generatedFile{
+		fname:  fname,
+		policy: affecting.GeneratedPolicy(),
+	}
+
+	switch gf.policy {
+	case pkgs.GeneratedSkip:
+		rw.log.Warn("skipping generated file, which must be regenerated by hand", "file", fname)
+		rw.report.Skip(fname, false, report.SkippedGenerated)
+	case pkgs.GeneratedRegenerate:
+		directives, err := rw.generateDirectives(filepath.Dir(fname))
+		if err != nil {
+			return "", false, fmt.Errorf("could not read go:generate directives: %v", err)
+		}
+
+		gf.directives = pkgs.DirectivesGenerating(directives, fname, pkgs.GeneratedBy(file))
+		if len(gf.directives) == 0 {
+			rw.log.Warn("no go:generate directive regenerates generated file, which must be regenerated by hand",
+				"file", fname)
+			rw.report.Skip(fname, false, report.SkippedGenerated)
+			break
+		}
+
+		before, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return "", false, fmt.Errorf("could not read %s: %v", fname, err)
+		}
+		gf.before = before
+	}
+
+	gf.report = &report.Generated{Path: fname, Policy: gf.policy}
+	for _, d := range gf.directives {
+		gf.report.Directives = append(gf.report.Directives, d.String())
+	}
+	rw.report.Affected(gf.report)
+
+	rw.generatedMu.Lock()
+	rw.generated = append(rw.generated, gf)
+	rw.generatedMu.Unlock()
+
+	return gf.policy, gf.policy == pkgs.GeneratedRewrite || len(gf.directives) != 0, nil
+}
+
+// generateDirectives returns the go:generate directives in the package in the
+// given directory, reading them once per directory. The directives are read
+// before anything is rewritten, but rewriting only changes import paths and
+// package names, which directives don't refer to.
+func (rw *rewriter) generateDirectives(dir string) ([]*pkgs.GenerateDirective, error) {
+	rw.generatedMu.Lock()
+	defer rw.generatedMu.Unlock()
+
+	if directives, ok := rw.directives[dir]; ok {
+		return directives, nil
+	}
+
+	directives, err := pkgs.ReadGenerateDirectives(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.directives[dir] = directives
+	return directives, nil
+}
+
+// regenerating returns true if any generated files are to be regenerated.
+func (rw *rewriter) regenerating() bool {
+	for _, gf := range rw.generated {
+		if len(gf.directives) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// regenerate runs the go:generate directives that regenerate the generated
+// files with the regenerate policy, given the changes written by the rewrite,
+// and returns those changes updated with the regenerated files. Must be run
+// once the rewrites have been written, so the generators see the moved
+// packages. A generator that fails is reported against the files it generates,
+// which are left as rewritten, rather than failing the run.
+func (rw *rewriter) regenerate(changes []*astio.Change) ([]*astio.Change, error) {
+	var (
+		directives []*pkgs.GenerateDirective
+		generates  = map[string][]*generatedFile{}
+	)
+
+	for _, gf := range rw.generated {
+		for _, d := range gf.directives {
+			key := d.String()
+			if _, seen := generates[key]; !seen {
+				directives = append(directives, d)
+			}
+			generates[key] = append(generates[key], gf)
+		}
+	}
+
+	sort.Slice(directives, func(i, j int) bool {
+		if directives[i].Filename != directives[j].Filename {
+			return directives[i].Filename < directives[j].Filename
+		}
+		return directives[i].Line < directives[j].Line
+	})
+
+	// NB(mmihic): Only the directives that regenerate the affected files are
+	// run, each by naming the file holding it and matching its exact text, so
+	// that unrelated generators in the same package are left alone.
+	for _, d := range directives {
+		rw.log.Info("regenerating", "directive", d.String())
+		cmd := exec.Command("go", "generate", "-run", d.RunPattern(), filepath.Base(d.Filename))
+		cmd.Dir = filepath.Dir(d.Filename)
+
+		// NB(mmihic): The generators' output is captured rather than passed
+		// through, so it can't interleave with JSON log lines or a JSON report.
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("go generate failed for %s: %v\n%s", d, err, bytes.TrimSpace(output))
+			for _, gf := range generates[d.String()] {
+				rw.log.Warn("could not regenerate generated file, leaving it rewritten instead",
+					"file", gf.fname, "error", err)
+				rw.report.RegenerateFailed(gf.report, err)
+			}
+			continue
+		}
+
+		if len(output) != 0 {
+			rw.log.Debug("go generate output", "directive", d.String(), "output", string(output))
+		}
+	}
+
+	rewritten := map[string]*astio.Change{}
+	for _, change := range changes {
+		rewritten[change.Filename] = change
+	}
+
+	for _, gf := range rw.generated {
+		if len(gf.directives) == 0 {
+			continue
+		}
+
+		after, err := ioutil.ReadFile(gf.fname)
+		if err != nil {
+			return changes, fmt.Errorf("could not read regenerated %s: %v", gf.fname, err)
+		}
+
+		if change, ok := rewritten[gf.fname]; ok {
+			change.After = after
+			continue
+		}
+
+		if !bytes.Equal(gf.before, after) {
+			changes = append(changes, &astio.Change{Filename: gf.fname, Before: gf.before, After: after})
+			rw.changed(&report.File{Path: gf.fname, Kind: report.KindGo, Generated: gf.policy})
+		}
+	}
+
+	return changes, nil
+}
+
+// reportGenerated lists the generated files affected by the moves, along with
+// how each was handled. The files are listed even if nothing was written.
+func (rw *rewriter) reportGenerated() {
+	if len(rw.generated) == 0 {
+		return
+	}
+
+	generated := append([]*generatedFile{}, rw.generated...)
+	sort.Slice(generated, func(i, j int) bool {
+		return generated[i].fname < generated[j].fname
+	})
+
+	for _, gf := range generated {
+		rw.log.Info("generated file affected by the moves", "policy", gf.policy, "file", gf.fname)
+		for _, d := range gf.directives {
+			rw.log.Info("regenerated by", "file", gf.fname, "directive", d.String())
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/pkgs"
+	"github.com/mmihic/go-tools/pkg/report"
+)
+
+// generatedTree is a tree with a generated file affected by a move, and a
+// generator that writes the file it is given, importing the moved package.
+var generatedTree = map[string]string{
+	"go.mod":   "module example.com/m\n\ngo 1.14\n",
+	"cfg.yaml": "packages:\n  - {from: first, to: other, generated: generate}\n",
+
+	"first/first.go": "package first\n\nfunc Do() {}\n",
+
+	"gen/main.go": `package main
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	src := "// Code generated by gen. DO NOT EDIT.\n\npackage use\n\nimport \"example.com/m/other\"\n\nvar _ = other.Do\n"
+	if err := ioutil.WriteFile(os.Args[1], []byte(src), 0644); err != nil {
+		panic(err)
+	}
+}
+`,
+
+	"use/use.go": "package use\n\n" +
+		"//go:generate go run ../gen names_gen.go\n" +
+		"//go:generate go run ../gen unrelated_gen.go\n",
+	"use/names_gen.go": "// Code generated by gen. DO NOT EDIT.\n\npackage use\n\n" +
+		"import \"example.com/m/first\"\n\nvar _ = first.Do\n",
+}
+
+func readReport(t *testing.T, fname string) *report.Report {
+	contents, err := ioutil.ReadFile(fname)
+	require.NoError(t, err)
+
+	var r report.Report
+	require.NoError(t, json.Unmarshal(contents, &r))
+	return &r
+}
+
+func TestRun_Regenerate(t *testing.T) {
+	inTree(t, generatedTree)
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet",
+		"--report", "json", "--report-file", "report.json", "."))
+
+	// Only the directive producing the affected file was run
+	assert.Equal(t, "// Code generated by gen. DO NOT EDIT.\n\npackage use\n\n"+
+		"import \"example.com/m/other\"\n\nvar _ = other.Do\n", readFile(t, "use/names_gen.go"))
+	_, err := os.Stat("use/unrelated_gen.go")
+	assert.True(t, os.IsNotExist(err), "unrelated directive was run")
+
+	r := readReport(t, "report.json")
+	assert.Equal(t, []*report.Generated{{
+		Path:       "use/names_gen.go",
+		Policy:     pkgs.GeneratedRegenerate,
+		Directives: []string{"use/use.go:3: //go:generate go run ../gen names_gen.go"},
+	}}, r.Generated)
+}
+
+func TestRun_RegenerateRolledBack(t *testing.T) {
+	files := map[string]string{
+		"broken/broken.go": "package broken\n\nimport \"example.com/m/first\"\n\nfunc {\n",
+	}
+	for fname, contents := range generatedTree {
+		files[fname] = contents
+	}
+	inTree(t, files)
+
+	err := runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet",
+		"--report", "json", "--report-file", "report.json", ".")
+	require.Error(t, err)
+
+	// Nothing was written or regenerated, but the affected file is reported
+	assert.Equal(t, generatedTree["use/names_gen.go"], readFile(t, "use/names_gen.go"))
+
+	r := readReport(t, "report.json")
+	require.Len(t, r.Generated, 1)
+	assert.Equal(t, "use/names_gen.go", r.Generated[0].Path)
+	assert.Equal(t, pkgs.GeneratedRegenerate, r.Generated[0].Policy)
+	assert.Equal(t, 0, r.Stats.FilesWritten)
+}
+
+func TestRun_RegenerateFails(t *testing.T) {
+	files := map[string]string{
+		"fail/main.go": "package main\n\nimport \"os\"\n\nfunc main() { os.Exit(1) }\n",
+		"use/use.go":   "package use\n\n//go:generate go run ../fail names_gen.go\n",
+		"use/other.go": "package use\n\nimport \"example.com/m/first\"\n\nvar _ = first.Do\n",
+	}
+	for fname, contents := range generatedTree {
+		if _, ok := files[fname]; !ok {
+			files[fname] = contents
+		}
+	}
+	inTree(t, files)
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet",
+		"--report", "json", "--report-file", "report.json", "."))
+
+	// The generated file is left rewritten, along with everything else
+	assert.Equal(t, "// Code generated by gen. DO NOT EDIT.\n\npackage use\n\n"+
+		"import \"example.com/m/other\"\n\nvar _ = other.Do\n", readFile(t, "use/names_gen.go"))
+	assert.Equal(t, "package use\n\nimport \"example.com/m/other\"\n\nvar _ = other.Do\n", readFile(t, "use/other.go"))
+
+	r := readReport(t, "report.json")
+	require.Len(t, r.Generated, 1)
+	assert.Equal(t, "use/names_gen.go", r.Generated[0].Path)
+	assert.Contains(t, r.Generated[0].Error, "go generate failed for use/use.go:3: //go:generate go run ../fail names_gen.go")
+	assert.Equal(t, 3, r.Stats.FilesWritten)
+}
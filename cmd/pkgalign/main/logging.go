@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/mmihic/go-tools/pkg/logging"
+)
+
+// logFlags are the flags controlling what is logged while rewriting.
+type logFlags struct {
+	LogLevel  string `enum:"quiet,normal,verbose,debug" default:"normal" help:"how much to log: quiet, normal, verbose, or debug"`
+	LogFormat string `enum:"text,json" default:"text" help:"the format of the log: text, or json lines"`
+}
+
+// logger creates a logger writing to w as configured by the flags.
+func (f *logFlags) logger(w io.Writer) (*logging.Logger, error) {
+	level, err := logging.ParseLevel(f.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	return logging.New(w, level, f.LogFormat)
+}
+
+// defaultLogger creates a logger writing text to standard output at the
+// normal level.
+func defaultLogger() *logging.Logger {
+	log, err := logging.New(os.Stdout, logging.Normal, logging.FormatText)
+	if err != nil {
+		panic(err)
+	}
+
+	return log
+}
@@ -1,25 +1,56 @@
+// Command pkgalign rewrites a tree of Go packages to reflect packages moving
+// to new import paths.
+//
+// pkgalign rewrites files in place and never moves them: the directories of
+// moved packages must be moved separately, for example with git mv, and
+// their other files go with them. Within the tree it rewrites the imports of
+// moved packages, the package clauses of the moved packages themselves and
+// the fully qualified symbol references in assembly files. With --vendor it
+// also rewrites vendored packages, but not vendor/modules.txt, which must
+// match go.mod and the vendored directories; when vendored packages move, the
+// vendor directory must be regenerated with go mod vendor.
+//
+// Other non-Go files, such as the .c and .h sources of cgo packages and .syso
+// objects, are neither moved nor rewritten. They don't refer to packages by
+// import path, so moving the directory is enough, but anything that spells
+// out a moved package's path by hand, such as a Makefile or a script, must be
+// updated separately.
 package main
 
 import (
 	"os"
 
 	"github.com/alecthomas/kong"
+
+	"github.com/mmihic/go-tools/pkg/journal"
 )
 
-var commands = struct {
-	Run runCmd `cmd:"" help:"runs the rewrite tool"`
-}{}
+type commands struct {
+	Run    runCmd    `cmd:"" help:"runs the rewrite tool"`
+	Undo   undoCmd   `cmd:"" help:"undoes a previous run using its journal"`
+	Rename renameCmd `cmd:"" help:"renames an identifier everywhere it is referenced"`
+}
 
-func main() {
+// newParser creates a parser for the command line, parsing into the given
+// commands.
+func newParser(cmds *commands) (*kong.Kong, error) {
 	helpOpt := kong.ConfigureHelp(kong.HelpOptions{
 		Tree: true,
 	})
-	parser, err := kong.New(&commands, helpOpt)
+	parser, err := kong.New(cmds, helpOpt, kong.Vars{"journal": journal.DefaultFilename})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	parser.Model.HelpFlag.Short = 'h'
+	return parser, nil
+}
+
+func main() {
+	parser, err := newParser(&commands{})
+	if err != nil {
+		panic(err)
+	}
 
 	kongCtx, err := parser.Parse(os.Args[1:])
 	parser.FatalIfErrorf(err)
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mmihic/go-tools/pkg/logging"
+)
+
+// progressInterval is how often progress is logged while rewriting.
+const progressInterval = 2 * time.Second
+
+// progress tracks how far a rewrite has got across all of the workers.
+type progress struct {
+	// NB(mmihic): The counters are updated atomically, so must come first to be
+	// 64-bit aligned on 32-bit platforms.
+	filesFound     int64
+	filesProcessed int64
+	filesChanged   int64
+	errors         int64
+
+	log  *logging.Logger
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// start logs progress periodically until stopped.
+func (p *progress) start(log *logging.Logger, interval time.Duration) {
+	p.log = log
+	p.done = make(chan struct{})
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.logProgress("progress")
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops logging progress, logging the final counts.
+func (p *progress) stop() {
+	close(p.done)
+	p.wg.Wait()
+	p.logProgress("finished processing")
+}
+
+func (p *progress) logProgress(msg string) {
+	p.log.Info(msg,
+		"files_processed", atomic.LoadInt64(&p.filesProcessed),
+		"files_found", atomic.LoadInt64(&p.filesFound),
+		"files_changed", atomic.LoadInt64(&p.filesChanged),
+		"errors", atomic.LoadInt64(&p.errors))
+}
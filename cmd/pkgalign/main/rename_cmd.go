@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/path"
+	"github.com/mmihic/go-tools/pkg/rename"
+)
+
+type renameCmd struct {
+	LocalPkgRoot string `short:"r" required:"" help:"the local package root"`
+	Dir          string `short:"d" default:"." help:"the root directory of the local package"`
+	Target       string `arg:"" required:"" help:"the identifier to rename, as pkg/path.Name or pkg/path.Type.Name"`
+	NewName      string `arg:"" required:"" help:"the new name"`
+
+	logFlags `embed:""`
+}
+
+// Run runs the rename.
+func (cmd *renameCmd) Run() error {
+	log, err := cmd.logger(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	targets, err := rename.ParseTargets(cmd.Target)
+	if err != nil {
+		return err
+	}
+
+	m, err := rename.Load(cmd.Dir, cmd.LocalPkgRoot)
+	if err != nil {
+		return err
+	}
+
+	for i, target := range targets {
+		targets[i] = target.ApplyPrefix(path.NewPath(cmd.LocalPkgRoot))
+	}
+
+	target, err := m.Resolve(targets)
+	if err != nil {
+		return err
+	}
+
+	changed, err := m.Rename(target, cmd.NewName)
+	if err != nil {
+		return err
+	}
+
+	tx := astio.NewTransaction()
+	for _, f := range changed {
+		log.Verbose("rewriting", "file", m.Filename(f))
+		if err := tx.WriteFile(m.Fset, f); err != nil {
+			return fmt.Errorf("error renaming in %s: %v", m.Filename(f), err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/multierr"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/buildtags"
+	"github.com/mmihic/go-tools/pkg/filter"
+	"github.com/mmihic/go-tools/pkg/imports"
+	"github.com/mmihic/go-tools/pkg/logging"
+	"github.com/mmihic/go-tools/pkg/path"
+	"github.com/mmihic/go-tools/pkg/pkgs"
+	"github.com/mmihic/go-tools/pkg/report"
+	"github.com/mmihic/go-tools/pkg/runner"
+	"github.com/mmihic/go-tools/pkg/scope"
+)
+
+// A rewriter rewrites a tree of packages to reflect a set of moves.
+type rewriter struct {
+	localPkgRoot string
+	moves        pkgs.Moves
+	prefilter    *pkgs.Prefilter
+	namer        *imports.Namer
+	applier      *pkgs.Applier
+	organizer    *imports.Organizer
+	maxParallel  int
+
+	// failFast stops rewriting files after the first error
+	failFast bool
+
+	// filter selects the directories and files to rewrite, relative to the
+	// directory being rewritten
+	filter *filter.Filter
+	root   string
+
+	// ignored, if set, reports paths that should not be rewritten
+	ignored func(path string) bool
+
+	// generated are the generated files affected by the moves
+	generatedMu sync.Mutex
+	generated   []*generatedFile
+	directives  map[string][]*pkgs.GenerateDirective
+
+	// log receives progress and summaries, report the details of the changes
+	log      *logging.Logger
+	report   *report.Report
+	progress *progress
+}
+
+// newRewriter creates a new rewriter for the given moves, which are relative
+// to the local package root. Imports under any of the local prefixes, or the
+// local package root if there are none, are grouped as local imports. Imports
+// are named using the alias policy, or the default policy if nil.
+func newRewriter(
+	localPkgRoot string, moves pkgs.Moves, localPrefixes []string, aliases imports.AliasPolicy,
+	f *filter.Filter, maxParallel int,
+) *rewriter {
+	root := path.NewPath(localPkgRoot)
+	rules := moves.ApplyPrefix(root)
+	namer := imports.NewNamer(rules.PkgNames(imports.NewSourcePkgNames(root, ".")), aliases)
+
+	if len(localPrefixes) == 0 {
+		localPrefixes = []string{localPkgRoot}
+	}
+
+	return &rewriter{
+		localPkgRoot: localPkgRoot,
+		moves:        rules,
+		prefilter:    rules.Prefilter(),
+		namer:        namer,
+		applier:      rules.Applier(namer),
+		organizer:    imports.NewOrganizer(localPrefixes...),
+		maxParallel:  maxParallel,
+		filter:       f,
+		log:          defaultLogger(),
+		report:       report.New(),
+		progress:     &progress{},
+	}
+}
+
+// rewrite stages the rewrites of all of the files under the given directory,
+// returning the transaction holding the rewrites along with any errors. Files
+// are rewritten in parallel, and once the context is done no more are started.
+func (rw *rewriter) rewrite(ctx context.Context, dir string) (*astio.Transaction, error) {
+	// NB(mmihic): Rewrites are staged and only written once the whole tree has
+	// been processed, so a failure part way through doesn't leave the tree
+	// half rewritten.
+	tx := astio.NewTransaction()
+	rw.root = dir
+	rw.generated = nil
+	rw.directives = map[string][]*pkgs.GenerateDirective{}
+	rw.report = report.New()
+	rw.progress = &progress{}
+
+	rw.progress.start(rw.log, progressInterval)
+	defer rw.progress.stop()
+
+	r := runner.New(ctx, runner.Options{MaxParallel: rw.maxParallel, FailFast: rw.failFast})
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if r.Context().Err() != nil {
+			return r.Context().Err()
+		}
+
+		// NB(mmihic): A path that can't be read is reported without giving up on
+		// the rest of the tree. The info is nil if the path couldn't be stat'd.
+		if err != nil {
+			r.Fail(pkgs.WithFilename(path, err))
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return rw.visitDir(r, path)
+		}
+
+		rw.visitFile(r, path, tx)
+		return nil
+	})
+
+	err := r.Wait()
+	if walkErr != nil && walkErr != r.Context().Err() {
+		err = multierr.Append(err, walkErr)
+	}
+
+	errs := multierr.Errors(err)
+	pkgs.SortErrors(errs)
+	return tx, multierr.Combine(errs...)
+}
+
+// visitDir schedules the checks of the given directory that aren't tied to a
+// single Go or assembly file, returning filepath.SkipDir if the directory
+// should be skipped.
+func (rw *rewriter) visitDir(r *runner.Runner, dir string) error {
+	if reason := rw.skipReason(dir, true); reason != "" {
+		rw.skipped(dir, true, reason)
+		return filepath.SkipDir
+	}
+
+	if rw.filter.Vendor && filepath.Base(dir) == "vendor" {
+		rw.schedule(r, func() error {
+			return rw.checkModulesTxt(filepath.Join(dir, "modules.txt"))
+		})
+	}
+
+	return nil
+}
+
+// visitFile schedules the rewrite of the file at the given path, if it is a Go
+// or assembly file that should be rewritten.
+func (rw *rewriter) visitFile(r *runner.Runner, fname string, tx *astio.Transaction) {
+	dir, name := filepath.Split(fname)
+	dir = filepath.Clean(dir)
+
+	ext := filepath.Ext(name)
+	if (ext != ".go" && ext != ".s") || !rw.include(dir, name) {
+		return
+	}
+
+	if ext == ".s" {
+		rw.schedule(r, func() error {
+			return rw.rewriteAsm(fname, tx)
+		})
+		return
+	}
+
+	pkgPath := path.NewPath(filepath.Join(rw.localPkgRoot, dir))
+	if rw.filter.Vendor {
+		// NB(mmihic): Vendored packages are imported by their original path,
+		// regardless of where the vendor directory lives.
+		if vendored, ok := pkgs.VendorPkgPath(dir); ok {
+			pkgPath = vendored
+		}
+	}
+
+	rw.schedule(r, func() error {
+		return multierr.Combine(rw.processFile(token.NewFileSet(), pkgPath, fname, tx)...)
+	})
+}
+
+// schedule runs the rewrite of a file on the runner, tracking its progress.
+func (rw *rewriter) schedule(r *runner.Runner, rewrite func() error) {
+	r.Go(func(context.Context) error {
+		// NB(mmihic): Files are only counted once the runner accepts them, since
+		// it refuses new tasks once canceled. Counting as the task starts, rather
+		// than once Go returns, keeps the files found ahead of those processed.
+		atomic.AddInt64(&rw.progress.filesFound, 1)
+		defer atomic.AddInt64(&rw.progress.filesProcessed, 1)
+
+		// NB(mmihic): A file is rewritten in memory in one go, so there's
+		// nothing to gain from abandoning it part way through on cancellation.
+		err := rewrite()
+		if err != nil {
+			atomic.AddInt64(&rw.progress.errors, 1)
+		}
+		return err
+	})
+}
+
+// include returns true if the named file in the given directory should be
+// rewritten, recording why it is skipped if not.
+func (rw *rewriter) include(dir, name string) bool {
+	fname := filepath.Join(dir, name)
+	reason := rw.skipReason(fname, false)
+	if reason == "" && rw.filter.SkipBuild(dir, name) {
+		reason = report.SkippedByPlatforms
+	}
+
+	if reason != "" {
+		rw.skipped(fname, false, reason)
+		return false
+	}
+
+	return true
+}
+
+// processFile stages the rewrite of the named Go file in the given package,
+// returning the errors encountered.
+func (rw *rewriter) processFile(fset *token.FileSet, pkgPath path.Path, fname string, tx *astio.Transaction) []error {
+	rw.log.Debug("processing", "file", fname)
+	rw.report.Processed()
+
+	src, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return []error{pkgs.WithFilename(fname, err)}
+	}
+
+	// NB(mmihic): Most files in a large tree import nothing that has moved, and
+	// ruling them out without parsing them is much faster.
+	if !rw.prefilter.MayAffect(pkgPath, src) {
+		return nil
+	}
+
+	file, err := parser.ParseFile(fset, fname, src, parser.ParseComments|parser.AllErrors)
+	if err != nil {
+		return rw.parseErrors(pkgPath, fname, file, err)
+	}
+
+	var policy pkgs.GeneratedPolicy
+	if affecting := rw.moves.Affecting(pkgPath, file); len(affecting) != 0 && pkgs.IsGenerated(file) {
+		var rewrite bool
+		if policy, rewrite, err = rw.handleGenerated(fname, file, affecting); err != nil {
+			return []error{pkgs.WithFilename(fname, err)}
+		}
+
+		if !rewrite {
+			return nil
+		}
+	}
+
+	changes, err := rw.applier.ApplyChanges(fset, pkgPath, file)
+	if err != nil {
+		return []error{pkgs.WithFilename(fname, err)}
+	}
+
+	if !changes.Changed() {
+		return nil
+	}
+
+	// Moves can leave behind duplicate, unused, or needlessly aliased imports
+	cleanedUp := rw.namer.Cleanup(fset, file, scope.NewIndexNamed(file, rw.namer), changes.RewrittenImports())
+
+	// Rewritten imports may now belong in a different group
+	src, err = astio.Bytes(fset, file)
+	if err != nil {
+		return []error{pkgs.WithFilename(fname, fmt.Errorf("error formatting: %v", err))}
+	}
+
+	src, err = rw.organizer.Organize(src)
+	if err != nil {
+		return []error{pkgs.WithFilename(fname, fmt.Errorf("error organizing imports: %v", err))}
+	}
+
+	if err := tx.WriteSource(fname, src); err != nil {
+		return []error{pkgs.WithFilename(fname, err)}
+	}
+
+	rw.changed(&report.File{
+		Path:             fname,
+		Kind:             report.KindGo,
+		BuildConstraints: buildtags.Parse(fname, file).String(),
+		Generated:        policy,
+		FileChanges:      changes,
+		ImportsCleanedUp: cleanedUp,
+	})
+	return nil
+}
+
+// parseErrors returns the errors for a file that failed to parse, given the
+// partial AST recovered by the parser, if any.
+func (rw *rewriter) parseErrors(pkgPath path.Path, fname string, file *ast.File, err error) []error {
+	if file == nil {
+		return pkgs.ParseErrors(fname, nil, err)
+	}
+
+	// NB(mmihic): A file with syntax errors is never rewritten, since printing
+	// its partial AST would drop whatever failed to parse. The partial AST is
+	// enough to tell whether the moves affect the file though, and there's no
+	// need to fail the run over a file that needs no rewriting.
+	affecting := rw.moves.Affecting(pkgPath, file)
+	if len(affecting) == 0 {
+		rw.log.Warn("ignoring syntax errors in a file unaffected by the moves", "file", fname, "error", err)
+		rw.skipped(fname, false, report.SkippedSyntaxErrors)
+		return nil
+	}
+
+	return pkgs.ParseErrors(fname, affecting[0], err)
+}
+
+// rewriteAsm stages the rewrite of the named assembly file. Other non-Go
+// files, such as the C sources and headers of cgo packages, never refer to
+// packages by import path and are left as is; since nothing is relocated,
+// they stay alongside the Go files of their package.
+func (rw *rewriter) rewriteAsm(fname string, tx *astio.Transaction) error {
+	rw.log.Debug("processing", "file", fname)
+	rw.report.Processed()
+
+	src, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return pkgs.WithFilename(fname, err)
+	}
+
+	rewritten, changed, err := rw.moves.RewriteAsm(src)
+	if err != nil {
+		return pkgs.WithFilename(fname, err)
+	}
+
+	if !changed {
+		return nil
+	}
+
+	if err := tx.WriteBytes(fname, rewritten); err != nil {
+		return pkgs.WithFilename(fname, err)
+	}
+
+	rw.changed(&report.File{
+		Path:             fname,
+		Kind:             report.KindAsm,
+		BuildConstraints: buildtags.ParseSource(fname, src).String(),
+	})
+	return nil
+}
+
+// checkModulesTxt warns if the given vendor/modules.txt file, if it exists,
+// lists packages affected by the moves. Rewriting the listing alone would leave
+// the vendor directory inconsistent, so it must be regenerated instead.
+func (rw *rewriter) checkModulesTxt(fname string) error {
+	contents, err := ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return pkgs.WithFilename(fname, err)
+	}
+
+	moved := rw.moves.MovedVendoredPkgs(contents)
+	if len(moved) == 0 {
+		return nil
+	}
+
+	rw.log.Warn("vendored packages moved, run go mod vendor once go.mod requires their new paths",
+		"file", fname, "packages", strings.Join(moved, ", "))
+	rw.report.Skip(fname, false, report.SkippedVendorModules)
+	return nil
+}
+
+// skipReason returns the reason the file or directory at the given path
+// should be skipped, or an empty string if it shouldn't be.
+func (rw *rewriter) skipReason(path string, dir bool) string {
+	if rw.ignored != nil && rw.ignored(path) {
+		return report.SkippedByGit
+	}
+
+	rel, err := filepath.Rel(rw.root, path)
+	switch {
+	case err != nil:
+		return ""
+	case dir && rw.filter.SkipDir(rel):
+		// Directories skipped even without any globs are skipped by convention
+		if (&filter.Filter{Vendor: rw.filter.Vendor}).SkipDir(rel) {
+			return report.SkippedByGo
+		}
+		return report.SkippedByFilter
+	case !dir && rw.filter.SkipFile(rel):
+		return report.SkippedByFilter
+	default:
+		return ""
+	}
+}
+
+// skipped records a file or directory that was skipped.
+func (rw *rewriter) skipped(path string, dir bool, reason string) {
+	rw.log.Debug("skipping", "path", path, "reason", reason)
+	rw.report.Skip(path, dir, reason)
+}
+
+// changed records a file that was changed.
+func (rw *rewriter) changed(f *report.File) {
+	atomic.AddInt64(&rw.progress.filesChanged, 1)
+	rw.report.Changed(f)
+}
+
+// commit writes the rewrites staged in the transaction.
+func (rw *rewriter) commit(tx *astio.Transaction) error {
+	fnames := tx.Files()
+	for _, fname := range fnames {
+		rw.log.Verbose("rewriting", "file", fname)
+	}
+
+	if len(fnames) != 0 {
+		rw.log.Info("writing rewritten files", "files", len(fnames))
+	}
+
+	return tx.Commit()
+}
+
+// reportConstraints summarizes the rewritten Go and assembly files by their
+// build constraints, so that rewrites of files which don't build on the
+// current platform are easy to spot.
+func (rw *rewriter) reportConstraints(changes []*astio.Change) {
+	counts := map[string]int{}
+	for _, change := range changes {
+		if ext := filepath.Ext(change.Filename); ext != ".go" && ext != ".s" {
+			continue
+		}
+
+		counts[buildtags.ParseSource(change.Filename, change.After).String()]++
+	}
+
+	if len(counts) == 0 {
+		return
+	}
+
+	var constraints []string
+	for constraint := range counts {
+		constraints = append(constraints, constraint)
+	}
+	sort.Strings(constraints)
+
+	for _, constraint := range constraints {
+		label := constraint
+		if label == "" {
+			label = "(none)"
+		}
+		rw.log.Info("rewritten files by build constraint", "constraint", label, "files", counts[constraint])
+	}
+}
+
+// reportErrors logs a table of the given errors, in the order given, returning
+// the error to exit with. With more than one error the table is the only place
+// they're listed in full.
+func (rw *rewriter) reportErrors(err error) error {
+	errs := multierr.Errors(err)
+	if len(errs) < 2 {
+		return err
+	}
+
+	rows := make([][]string, 0, len(errs))
+	for _, err := range errs {
+		rwErr, ok := err.(*pkgs.RewriteError)
+		if !ok {
+			rows = append(rows, []string{"-", "-", "-", err.Error()})
+			continue
+		}
+
+		pos, move := "-", "-"
+		if rwErr.Pos.Line > 0 {
+			pos = fmt.Sprintf("%d:%d", rwErr.Pos.Line, rwErr.Pos.Column)
+		}
+
+		if rwErr.Move != nil {
+			move = fmt.Sprintf("%s -> %s", rwErr.Move.From, rwErr.Move.To)
+		}
+
+		rows = append(rows, []string{rwErr.Filename, pos, move, rwErr.Cause.Error()})
+	}
+
+	rw.log.Table(logging.Quiet, "errors", []string{"file", "position", "move", "error"}, rows)
+	return fmt.Errorf("%d errors, listed above", len(errs))
+}
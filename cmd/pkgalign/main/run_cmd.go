@@ -1,38 +1,67 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"go/parser"
-	"go/token"
 	"io/ioutil"
 	"os"
-	"path/filepath"
-	"sync"
+	"strings"
 
 	"go.uber.org/multierr"
 	"gopkg.in/yaml.v2"
 
 	"github.com/mmihic/go-tools/pkg/astio"
-	"github.com/mmihic/go-tools/pkg/path"
+	"github.com/mmihic/go-tools/pkg/filter"
+	"github.com/mmihic/go-tools/pkg/git"
+	"github.com/mmihic/go-tools/pkg/imports"
+	"github.com/mmihic/go-tools/pkg/journal"
 	"github.com/mmihic/go-tools/pkg/pkgs"
+	"github.com/mmihic/go-tools/pkg/report"
 )
 
 type runCmd struct {
-	File         string `short:"f" required:"" help:"name of the configuration file"`
-	LocalPkgRoot string `short:"r" required:"" help:"the local package root"`
-	Dir          string `arg:"" required:"" help:"the directory to start from"`
-	MaxParallel  int    `arg:"" default:"10" help:"max parallelism"`
+	File         string   `short:"f" required:"" help:"name of the configuration file"`
+	LocalPkgRoot string   `short:"r" required:"" help:"the local package root"`
+	Local        []string `short:"l" help:"import path prefixes grouped as local imports, defaults to the local package root"`
+	Journal      string   `short:"j" default:"${journal}" help:"where to write the journal used to undo the run"`
+	NoJournal    bool     `help:"don't write a journal"`
+	Dir          string   `arg:"" required:"" help:"the directory to start from"`
+	MaxParallel  int      `arg:"" default:"10" help:"max parallelism"`
+	KeepGoing    bool     `short:"k" help:"write the files that were rewritten successfully even if others failed"`
+	FailFast     bool     `help:"stop rewriting files after the first error"`
+	Git          bool     `help:"skip paths ignored by git, require a clean working tree, and stage the rewritten files"`
+	AllowDirty   bool     `help:"with --git, run even if the working tree has uncommitted changes"`
+	Commit       bool     `help:"commit the rewritten files, and only those, with a message listing the moves, implies --git"`
+	Include      []string `help:"globs of the files to rewrite, in addition to those in the configuration"`
+	Exclude      []string `help:"globs of the directories and files to skip, in addition to those in the configuration"`
+	Vendor       bool     `help:"also rewrite vendored packages, warning if vendor/modules.txt lists moved packages"`
+	Platform     []string `help:"only rewrite files that build for one of these GOOS/GOARCH platforms, in addition to those in the configuration"`
+	Tags         []string `help:"build tags to use with --platform"`
+	Generated    string   `help:"how to handle generated files affected by moves without a policy of their own: rewrite, skip, or generate"`
+	Report       string   `enum:"text,json" default:"text" help:"the format of the report of the changes made: text or json"`
+	ReportFile   string   `help:"write a json report to this file rather than standard output"`
+
+	logFlags `embed:""`
 }
 
+const reportJSON = "json"
+
 // Run runs the rewrite tool
 func (cmd *runCmd) Run() error {
+	if cmd.KeepGoing && cmd.FailFast {
+		return fmt.Errorf("--keep-going and --fail-fast can't be used together")
+	}
+
 	contents, err := ioutil.ReadFile(cmd.File)
 	if err != nil {
 		return err
 	}
 
 	type config struct {
-		PkgMoves pkgs.Moves `yaml:"packages"`
+		PkgMoves    pkgs.Moves           `yaml:"packages"`
+		AliasPolicy imports.AliasConfig  `yaml:"alias_policy"`
+		Filter      filter.Filter        `yaml:",inline"`
+		Generated   pkgs.GeneratedPolicy `yaml:"generated"`
 	}
 
 	var cfg config
@@ -40,80 +69,224 @@ func (cmd *runCmd) Run() error {
 		return fmt.Errorf("unable to parse config: %v", err)
 	}
 
-	rules := cfg.PkgMoves.ApplyPrefix(path.NewPath(cmd.LocalPkgRoot))
+	cfg.Filter.Include = append(cfg.Filter.Include, cmd.Include...)
+	cfg.Filter.Exclude = append(cfg.Filter.Exclude, cmd.Exclude...)
+	cfg.Filter.Vendor = cfg.Filter.Vendor || cmd.Vendor
+	cfg.Filter.Platforms = append(cfg.Filter.Platforms, cmd.Platform...)
+	cfg.Filter.Tags = append(cfg.Filter.Tags, cmd.Tags...)
+	if err := cfg.Filter.Validate(); err != nil {
+		return err
+	}
+
+	if cmd.Generated != "" {
+		cfg.Generated = pkgs.GeneratedPolicy(cmd.Generated)
+		if err := cfg.Generated.Validate(); err != nil {
+			return err
+		}
+	}
+	cfg.PkgMoves = cfg.PkgMoves.WithGeneratedPolicy(cfg.Generated)
+
+	rw := newRewriter(cmd.LocalPkgRoot, cfg.PkgMoves, cmd.Local, &cfg.AliasPolicy, &cfg.Filter, cmd.MaxParallel)
+	rw.failFast = cmd.FailFast
+
+	var repo *git.Repo
+	if cmd.Git || cmd.Commit {
+		if repo, err = cmd.openRepo(); err != nil {
+			return err
+		}
+
+		if rw.ignored, err = repo.Ignored(); err != nil {
+			return err
+		}
+	}
+
+	// NB(mmihic): A JSON report written to standard output mustn't be mixed
+	// with the log.
+	logOut := os.Stdout
+	if cmd.Report == reportJSON && cmd.ReportFile == "" {
+		logOut = os.Stderr
+	}
+
+	if rw.log, err = cmd.logger(logOut); err != nil {
+		return err
+	}
+
+	j := &journal.Journal{
+		LocalPkgRoot: cmd.LocalPkgRoot,
+		Dir:          cmd.Dir,
+		Local:        cmd.Local,
+		Filter:       cfg.Filter,
+		Moves:        cfg.PkgMoves,
+	}
+
+	ctx, stop := interruptible(rw.log)
+	defer stop()
+
+	changes, err := cmd.apply(ctx, rw, repo, j)
+	rw.reportGenerated()
+	if cmd.Report == reportJSON {
+		var written []string
+		for _, change := range changes {
+			written = append(written, change.Filename)
+		}
+		rw.report.Finish(written, err)
 
-	var (
-		wg      sync.WaitGroup
-		errorCh = make(chan error, 1000)
-		dirsCh  = make(chan string, 1000)
-		allDone = make(chan struct{})
-		allErr  error
-	)
+		if reportErr := cmd.writeReport(rw.report); reportErr != nil {
+			err = multierr.Append(err, fmt.Errorf("unable to write report: %v", reportErr))
+		}
+	}
 
-	// Process directories in parallel
-	for i := 0; i < cmd.MaxParallel; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
+	return rw.reportErrors(err)
+}
 
-			for dir := range dirsCh {
-				if err := cmd.processDir(dir, rules); err != nil {
-					errorCh <- err
-				}
+// apply rewrites the tree, returning the changes that were written. The
+// changes are recorded in the journal before they are written, unless
+// disabled, and then staged in git if the repo is given. Nothing is written if
+// the context is done before the tree has been rewritten, but once writing
+// starts it runs to completion.
+func (cmd *runCmd) apply(ctx context.Context, rw *rewriter, repo *git.Repo, j *journal.Journal) ([]*astio.Change, error) {
+	tx, rewriteErr := rw.rewrite(ctx, cmd.Dir)
+	if ctx.Err() != nil {
+		tx.Rollback()
+		rw.log.Warn("interrupted, no files were rewritten")
+		return nil, rewriteErr
+	}
+
+	if rewriteErr != nil && !cmd.KeepGoing {
+		tx.Rollback()
+		rw.log.Warn("no files were rewritten, use --keep-going to write the files rewritten without errors")
+		return nil, rewriteErr
+	}
+
+	// NB(mmihic): The journal is written before any of the files are, and
+	// marked complete once they all have been, so that a run which dies part
+	// way through writing can still be undone. Generated files are recorded as
+	// pending until they have been regenerated.
+	changes := tx.Changes()
+	journaled := !cmd.NoJournal && (len(changes) != 0 || rw.regenerating())
+	if journaled {
+		j.Record(changes)
+		for _, gf := range rw.generated {
+			if len(gf.directives) != 0 {
+				j.RecordPending(gf.fname, gf.before)
 			}
-		}()
+		}
+
+		if err := j.Write(cmd.Journal); err != nil {
+			tx.Rollback()
+			return nil, multierr.Append(rewriteErr, fmt.Errorf("unable to write journal, no files were rewritten: %v", err))
+		}
+	}
+
+	if err := rw.commit(tx); err != nil {
+		return nil, multierr.Append(rewriteErr, err)
 	}
 
-	// Combine errors
-	go func() {
-		for err := range errorCh {
-			allErr = multierr.Append(allErr, err)
+	changes, err := rw.regenerate(changes)
+	rewriteErr = multierr.Append(rewriteErr, err)
+
+	rw.reportConstraints(changes)
+
+	// The journal is completed before staging, so that the files written
+	// can be undone even if staging or committing them fails
+	if journaled {
+		if err := cmd.completeJournal(j, changes); err != nil {
+			rewriteErr = multierr.Append(rewriteErr, fmt.Errorf("unable to write journal: %v", err))
 		}
-		close(allDone)
-	}()
+	}
 
-	// Feed in all the directories
-	if err := filepath.Walk(cmd.Dir, func(path string, info os.FileInfo, err error) error {
-		if info.IsDir() {
-			dirsCh <- path
+	if repo != nil {
+		if err := cmd.stage(repo, changes, rw.moves); err != nil {
+			return changes, multierr.Append(rewriteErr, err)
 		}
-		return nil
-	}); err != nil {
+	}
+
+	return changes, rewriteErr
+}
+
+// completeJournal records the changes as finally written, including those of
+// regenerated files, in the journal and marks it complete, removing it if
+// regeneration turned out to change nothing either.
+func (cmd *runCmd) completeJournal(j *journal.Journal, changes []*astio.Change) error {
+	j.Record(changes)
+	j.MarkComplete()
+	if len(j.Files) == 0 {
+		return os.Remove(cmd.Journal)
+	}
+
+	return j.Write(cmd.Journal)
+}
+
+// writeReport writes the JSON report to the report file, or to standard output.
+func (cmd *runCmd) writeReport(r *report.Report) error {
+	if cmd.ReportFile == "" {
+		return r.WriteJSON(os.Stdout)
+	}
+
+	f, err := os.Create(cmd.ReportFile)
+	if err != nil {
 		return err
 	}
-	close(dirsCh)
 
-	// Wait for everything to complete
-	wg.Wait()
-	close(errorCh)
-	<-allDone
-	return allErr
+	if err := r.WriteJSON(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
 }
 
-func (cmd *runCmd) processDir(dir string, moves pkgs.Moves) error {
-	fset := token.NewFileSet()
-	pkgPath := path.NewPath(filepath.Join(cmd.LocalPkgRoot, dir))
-	packages, err := parser.ParseDir(fset, dir, nil, parser.ParseComments)
+// openRepo opens the git repository being rewritten, checking that it has no
+// uncommitted changes unless those are allowed. The journal is never committed,
+// so it doesn't count as an uncommitted change.
+func (cmd *runCmd) openRepo() (*git.Repo, error) {
+	repo, err := git.Open(cmd.Dir)
 	if err != nil {
-		return fmt.Errorf("could not parse %s: %v", dir, err)
+		return nil, err
 	}
 
-	for _, pkg := range packages {
-		for _, file := range pkg.Files {
-			fname := fset.File(file.Pos())
-			fmt.Printf("processing %s\n", fname.Name())
-			changed, err := moves.Apply(fset, pkgPath, file)
-			if err != nil {
-				return fmt.Errorf("error applying moves to %s: %v", fname.Name(), err)
-			}
+	if cmd.AllowDirty {
+		return repo, nil
+	}
 
-			if changed {
-				if err := astio.WriteFile(fset, file); err != nil {
-					return fmt.Errorf("error applying moves to %s: %v", fname.Name(), err)
-				}
-			}
-		}
+	dirty, err := repo.Dirty(cmd.Journal)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dirty) != 0 {
+		return nil, fmt.Errorf("%s has uncommitted changes (%s), use --allow-dirty to run anyway",
+			repo.Root, strings.Join(dirty, ", "))
+	}
+
+	return repo, nil
+}
+
+// stage stages the rewritten files, committing them if requested.
+func (cmd *runCmd) stage(repo *git.Repo, changes []*astio.Change, moves pkgs.Moves) error {
+	var fnames []string
+	for _, change := range changes {
+		fnames = append(fnames, change.Filename)
+	}
+
+	if err := repo.Add(fnames...); err != nil {
+		return err
+	}
+
+	if !cmd.Commit || len(fnames) == 0 {
+		return nil
+	}
+
+	return repo.Commit(commitMessage(moves), fnames...)
+}
+
+// commitMessage generates the message for a commit applying the given moves.
+func commitMessage(moves pkgs.Moves) string {
+	var msg strings.Builder
+	msg.WriteString("Move packages\n\n")
+	for _, mv := range moves {
+		fmt.Fprintf(&msg, "- %s -> %s\n", strings.Join(mv.From, "/"), strings.Join(mv.To, "/"))
 	}
 
-	return nil
+	return msg.String()
 }
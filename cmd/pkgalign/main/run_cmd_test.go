@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/report"
+)
+
+func TestRun_SeparatesImportGroups(t *testing.T) {
+	inTree(t, map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.14\n",
+		"cfg.yaml":       "packages:\n  - first:other\n",
+		"first/first.go": "package first\n\nfunc Do() {}\n",
+
+		// The moved import stays in place, but moves into a group of its own
+		"cmd/main.go": "package main\n\nimport (\n\t\"fmt\"\n\t\"example.com/m/first\"\n)\n\n" +
+			"func main() { fmt.Println(first.Do) }\n",
+	})
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet", "."))
+	assert.Equal(t, "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/m/other\"\n)\n\n"+
+		"func main() { fmt.Println(other.Do) }\n", readFile(t, "cmd/main.go"))
+}
+
+func TestRun_VendoredMoves(t *testing.T) {
+	modulesTxt := "# example.com/m/lib v1.2.0\n## explicit\nexample.com/m/lib/old\n"
+	inTree(t, map[string]string{
+		"go.mod":   "module example.com/m\n\ngo 1.14\n",
+		"cfg.yaml": "packages:\n  - lib/old:lib/fresh\n",
+
+		// An application vendoring the library it lives alongside
+		"app/vendor/modules.txt":                  modulesTxt,
+		"app/vendor/example.com/m/lib/old/a.go":   "package old\n\nfunc Do() {}\n",
+		"app/vendor/example.com/m/lib/use/use.go": "package use\n\nimport \"example.com/m/lib/old\"\n\nvar _ = old.Do\n",
+	})
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--vendor", "--log-level", "quiet",
+		"--report", "json", "--report-file", "report.json", "."))
+	assert.Equal(t, "package use\n\nimport \"example.com/m/lib/fresh\"\n\nvar _ = fresh.Do\n",
+		readFile(t, "app/vendor/example.com/m/lib/use/use.go"))
+
+	// The listing is left for go mod vendor to regenerate along with the
+	// vendored directories
+	assert.Equal(t, modulesTxt, readFile(t, "app/vendor/modules.txt"))
+
+	r := readReport(t, "report.json")
+	assert.Contains(t, r.Skipped, &report.Skipped{Path: "app/vendor/modules.txt", Reason: report.SkippedVendorModules})
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mmihic/go-tools/pkg/logging"
+)
+
+// interruptible returns a context that is canceled on the first interrupt, so
+// that no more files are rewritten while those in flight are finished, along
+// with a function to stop listening for interrupts. A second interrupt exits
+// immediately.
+func interruptible(log *logging.Logger) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigCh:
+		case <-done:
+			return
+		}
+
+		log.Warn("interrupted, finishing the files in flight; interrupt again to exit immediately")
+		cancel()
+
+		select {
+		case <-sigCh:
+			log.Warn("interrupted again, exiting; files may be left partially rewritten, use undo to restore them")
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigCh)
+		close(done)
+		cancel()
+	}
+}
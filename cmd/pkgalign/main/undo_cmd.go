@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"go.uber.org/multierr"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/journal"
+)
+
+type undoCmd struct {
+	Journal string `short:"j" default:"${journal}" help:"the journal of the run to undo"`
+
+	logFlags `embed:""`
+}
+
+// Run reverses a previous run, by restoring the original contents of the files
+// it rewrote. Rewriting the files with the inverse moves wouldn't restore them
+// exactly, since the run also regroups imports and renames packages.
+func (cmd *undoCmd) Run() error {
+	log, err := cmd.logger(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	j, err := journal.Read(cmd.Journal)
+	if err != nil {
+		return err
+	}
+
+	if err := j.Verify(); err != nil {
+		return fmt.Errorf("refusing to undo: %v", err)
+	}
+
+	if !j.Complete {
+		log.Warn("the run was interrupted while writing files, restoring those it wrote")
+	}
+
+	tx := astio.NewTransaction()
+	created, err := j.Restore(tx)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("refusing to undo: %v", err)
+	}
+
+	log.Info("restoring files", "files", len(tx.Files()))
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	var errs error
+	for _, fname := range created {
+		log.Info("removing file created by the run", "file", fname)
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			errs = multierr.Append(errs, err)
+		}
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	// The journal no longer describes the tree
+	return os.Remove(cmd.Journal)
+}
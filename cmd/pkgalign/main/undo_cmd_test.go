@@ -0,0 +1,131 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/journal"
+)
+
+// inTree writes the files to a new temporary directory and changes into it for
+// the duration of the test, as pkgalign is run from the root of the tree.
+func inTree(t *testing.T, files map[string]string) {
+	dir, err := ioutil.TempDir("", "pkgalign")
+	require.NoError(t, err)
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		_ = os.RemoveAll(dir)
+	})
+
+	for fname, contents := range files {
+		fname = filepath.Join(dir, filepath.FromSlash(fname))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0755))
+		require.NoError(t, ioutil.WriteFile(fname, []byte(contents), 0644))
+	}
+
+	require.NoError(t, os.Chdir(dir))
+}
+
+// runPkgalign runs pkgalign with the given arguments.
+func runPkgalign(t *testing.T, args ...string) error {
+	parser, err := newParser(&commands{})
+	require.NoError(t, err)
+
+	kongCtx, err := parser.Parse(args)
+	require.NoError(t, err)
+	return kongCtx.Run()
+}
+
+func readFile(t *testing.T, fname string) string {
+	contents, err := ioutil.ReadFile(fname)
+	require.NoError(t, err)
+	return string(contents)
+}
+
+func TestUndo(t *testing.T) {
+	files := map[string]string{
+		"go.mod":   "module example.com/m\n\ngo 1.14\n",
+		"cfg.yaml": "packages:\n  - go-first:other\n",
+
+		// The package name differs from the last element of its path
+		"go-first/first.go": "// Package first does things.\npackage first\n\nfunc Do() {}\n",
+
+		// Imports the run regroups, with CRLF line endings
+		"cmd/main.go": "package main\r\n\r\nimport (\r\n\t\"example.com/m/go-first\"\r\n\t\"fmt\"\r\n)\r\n\r\n" +
+			"func main() { fmt.Println(first.Do) }\r\n",
+		"cmd/unaffected.go": "package main\n\nimport (\n\t\"os\"\n\t\"fmt\"\n)\n\nvar _, _ = fmt.Println, os.Exit\n",
+	}
+	inTree(t, files)
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet", "."))
+	assert.Equal(t, "// Package other does things.\npackage other\n\nfunc Do() {}\n", readFile(t, "go-first/first.go"))
+	assert.Equal(t, "package main\r\n\r\nimport (\r\n\t\"fmt\"\r\n\r\n\t\"example.com/m/other\"\r\n)\r\n\r\n"+
+		"func main() { fmt.Println(other.Do) }\r\n", readFile(t, "cmd/main.go"))
+
+	_, err := os.Stat(journal.DefaultFilename)
+	require.NoError(t, err)
+
+	require.NoError(t, runPkgalign(t, "undo", "--log-level", "quiet"))
+	for fname, contents := range files {
+		assert.Equal(t, contents, readFile(t, fname), fname)
+	}
+
+	_, err = os.Stat(journal.DefaultFilename)
+	assert.True(t, os.IsNotExist(err), "journal not removed")
+}
+
+func TestUndo_RefusesModifiedFiles(t *testing.T) {
+	inTree(t, map[string]string{
+		"go.mod":            "module example.com/m\n\ngo 1.14\n",
+		"cfg.yaml":          "packages:\n  - first:other\n",
+		"first/first.go":    "package first\n\nfunc Do() {}\n",
+		"cmd/main.go":       "package main\n\nimport \"example.com/m/first\"\n\nfunc main() { first.Do() }\n",
+		"cmd/unaffected.go": "package main\n",
+	})
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet", "."))
+	require.NoError(t, ioutil.WriteFile("cmd/main.go", []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	err := runPkgalign(t, "undo", "--log-level", "quiet")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cmd/main.go")
+	}
+
+	// Nothing was restored
+	assert.Equal(t, "package other\n\nfunc Do() {}\n", readFile(t, "first/first.go"))
+	_, err = os.Stat(journal.DefaultFilename)
+	assert.NoError(t, err)
+}
+
+func TestUndo_Interrupted(t *testing.T) {
+	files := map[string]string{
+		"go.mod":         "module example.com/m\n\ngo 1.14\n",
+		"cfg.yaml":       "packages:\n  - first:other\n",
+		"first/first.go": "package first\n\nfunc Do() {}\n",
+		"cmd/main.go":    "package main\n\nimport \"example.com/m/first\"\n\nfunc main() { first.Do() }\n",
+	}
+	inTree(t, files)
+
+	require.NoError(t, runPkgalign(t, "run", "-f", "cfg.yaml", "-r", "example.com/m", "--log-level", "quiet", "."))
+	j, err := journal.Read(journal.DefaultFilename)
+	require.NoError(t, err)
+	assert.True(t, j.Complete)
+
+	// Simulate a run that died after writing the journal and only one file
+	j.Complete = false
+	require.NoError(t, j.Write(journal.DefaultFilename))
+	require.NoError(t, ioutil.WriteFile("cmd/main.go", []byte(files["cmd/main.go"]), 0644))
+
+	require.NoError(t, runPkgalign(t, "undo", "--log-level", "quiet"))
+	for fname, contents := range files {
+		assert.Equal(t, contents, readFile(t, fname), fname)
+	}
+}
@@ -1,20 +1,29 @@
 module github.com/mmihic/go-tools
 
-go 1.13
+go 1.18
 
 require (
-	github.com/Masterminds/semver v1.5.0 // indirect
 	github.com/alecthomas/kong v0.2.9
-	github.com/apex/log v1.4.0 // indirect
 	github.com/axw/gocov v1.0.0
+	github.com/fossas/fossa-cli v1.0.30
+	github.com/golangci/golangci-lint v1.27.0
+	github.com/stretchr/testify v1.5.1
+	go.uber.org/multierr v1.5.0
+	golang.org/x/mod v0.2.0
+	golang.org/x/tools v0.0.0-20200626171337-aa94e735be7f
+	gopkg.in/yaml.v2 v2.3.0
+)
+
+require (
+	github.com/Masterminds/semver v1.5.0 // indirect
+	github.com/apex/log v1.4.0 // indirect
 	github.com/bmatcuk/doublestar v1.3.1 // indirect
 	github.com/briandowns/spinner v1.11.1 // indirect
 	github.com/cheekybits/genny v1.0.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fatih/color v1.9.0 // indirect
-	github.com/fossas/fossa-cli v1.0.30
 	github.com/gnewton/jargo v0.0.0-20150417131352-41f5f186a805 // indirect
 	github.com/golang/mock v1.4.3 // indirect
-	github.com/golangci/golangci-lint v1.27.0
 	github.com/m3db/build-tools v0.0.0-20181013000606-edd1bdd1df8a // indirect
 	github.com/m3db/m3x v0.0.0-20190408051622-ebf3c7b94afd // indirect
 	github.com/mattn/go-isatty v0.0.12 // indirect
@@ -22,14 +31,13 @@ require (
 	github.com/mitchellh/mapstructure v1.3.2 // indirect
 	github.com/olekukonko/tablewriter v0.0.4 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/remeh/sizedwaitgroup v1.0.0 // indirect
 	github.com/rhysd/go-github-selfupdate v1.2.2 // indirect
 	github.com/rveen/ogdl v0.0.0-20200522080342-eeeda1a978e7 // indirect
-	github.com/stretchr/testify v1.5.1
 	github.com/urfave/cli v1.22.4 // indirect
-	go.uber.org/multierr v1.5.0
-	golang.org/x/tools v0.0.0-20200626171337-aa94e735be7f
+	go.uber.org/atomic v1.6.0 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
 	gopkg.in/go-ini/ini.v1 v1.57.0 // indirect
 	gopkg.in/src-d/go-git.v4 v4.13.1 // indirect
-	gopkg.in/yaml.v2 v2.3.0
 )
@@ -5,31 +5,302 @@ import (
 	"bytes"
 	"go/ast"
 	"go/format"
+	"go/parser"
 	"go/token"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/textedit"
 )
 
 // String converts the given node to a string.
 func String(fset *token.FileSet, n ast.Node) (string, error) {
+	b, err := Bytes(fset, n)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// Bytes converts the given node to formatted source.
+func Bytes(fset *token.FileSet, n ast.Node) ([]byte, error) {
 	var buf bytes.Buffer
 	if err := format.Node(&buf, fset, n); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return buf.String(), nil
+	return buf.Bytes(), nil
 }
 
 // WriteFile writes the given file.
 func WriteFile(fset *token.FileSet, f *ast.File) error {
-	fname := fset.File(f.Pos())
-	file, err := os.Create(fname.Name())
+	src, err := Bytes(fset, f)
+	if err != nil {
+		return err
+	}
+
+	return WriteSource(fset.File(f.Pos()).Name(), src)
+}
+
+// WriteSource writes the given source to the named file. If the file already
+// exists, only the parts of the source that changed are rewritten, so that
+// the layout and comments of the rest of the file are left untouched, as are
+// its line endings and byte order mark. The file is replaced atomically,
+// keeping its mode, so a failure never leaves it partially written.
+func WriteSource(fname string, src []byte) error {
+	staged, err := stage(fname, src, applyChanges)
+	if err != nil {
+		return err
+	}
+
+	return writeAtomic(staged.fname, staged.src, staged.info)
+}
+
+// A stagedFile is the new contents of a file, ready to be written.
+type stagedFile struct {
+	fname string
+	src   []byte
+
+	// The original contents and file info, nil if the file is new
+	orig []byte
+	info os.FileInfo
+}
+
+// stage computes the contents with which to replace the named file, merging
+// the new contents with the original if the file exists.
+func stage(fname string, src []byte, merge func(orig, updated []byte) ([]byte, error)) (*stagedFile, error) {
+	// Write through symlinks rather than replacing them
+	if resolved, err := filepath.EvalSymlinks(fname); err == nil {
+		fname = resolved
+	}
+
+	info, err := os.Stat(fname)
+	if os.IsNotExist(err) {
+		return &stagedFile{fname: fname, src: src}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	orig, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if src, err = merge(orig, src); err != nil {
+		return nil, err
+	}
+
+	return &stagedFile{fname: fname, src: src, orig: orig, info: info}, nil
+}
+
+var (
+	bom  = []byte("\xef\xbb\xbf")
+	crlf = []byte("\r\n")
+	lf   = []byte("\n")
+)
+
+// applyChanges applies the changes between the original and updated source to
+// the original source, using the original's line endings and byte order mark.
+func applyChanges(orig, updated []byte) ([]byte, error) {
+	orig = replaceImports(orig, updated)
+	edits, err := textedit.Diff(orig, restoreDocComments(orig, updated))
+	if err != nil {
+		return nil, err
+	}
+
+	// NB(mmihic): Updated source is always formatted with \n line endings,
+	// so convert any changed lines to match a file that uses \r\n.
+	if usesCRLF(orig) {
+		for i := range edits {
+			edits[i].NewText = bytes.Replace(edits[i].NewText, lf, crlf, -1)
+		}
+	}
+
+	src, err := textedit.Apply(orig, edits)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(orig, bom) && !bytes.HasPrefix(src, bom) {
+		src = append(append([]byte{}, bom...), src...)
+	}
+
+	return src, nil
+}
+
+// replaceImports replaces the import declarations of the original source with
+// those of the updated source, wherever they differ in more than spacing.
+// Changes are otherwise found token by token, which would lose the blank lines
+// separating groups of imports once they have been organized.
+func replaceImports(orig, updated []byte) []byte {
+	origDecls := importDecls(orig)
+	updatedDecls := importDecls(updated)
+
+	// NB(mmihic): Declarations can only be paired when none were added or
+	// removed; otherwise they are left to the token by token comparison.
+	if len(origDecls) == 0 || len(origDecls) != len(updatedDecls) {
+		return orig
+	}
+
+	crlfs := usesCRLF(orig)
+	for i := len(origDecls) - 1; i >= 0; i-- {
+		origDecl, updatedDecl := origDecls[i], updatedDecls[i]
+		text := updated[updatedDecl[0]:updatedDecl[1]]
+		if withoutSpacing(orig[origDecl[0]:origDecl[1]]) == withoutSpacing(text) {
+			continue
+		}
+
+		if crlfs {
+			text = bytes.Replace(text, lf, crlf, -1)
+		}
+
+		orig = append(append(append([]byte{}, orig[:origDecl[0]]...), text...), orig[origDecl[1]:]...)
+	}
+
+	return orig
+}
+
+// importDecls returns the byte ranges of the import declarations in the
+// source, or nil if it can't be parsed.
+func importDecls(src []byte) [][2]int {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+
+	var decls [][2]int
+	tokFile := fset.File(f.Pos())
+	for _, decl := range f.Decls {
+		if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+			decls = append(decls, [2]int{tokFile.Offset(gen.Pos()), tokFile.Offset(gen.End())})
+		}
+	}
+
+	return decls
+}
+
+// withoutSpacing returns the source without spaces, tabs and carriage returns,
+// keeping the line breaks.
+func withoutSpacing(src []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' {
+			return -1
+		}
+		return r
+	}, string(src))
+}
+
+// restoreDocComments undoes the printer's reformatting of top-level doc
+// comments that were otherwise left alone. The printer moves directives, such
+// as cgo's //export, to the end of the comment behind a blank // line; since
+// a file is reprinted whole, this would otherwise rewrite every such comment
+// in the file.
+func restoreDocComments(orig, updated []byte) []byte {
+	origFile, err := parser.ParseFile(token.NewFileSet(), "", orig, parser.ParseComments)
+	if err != nil {
+		return updated
+	}
+
+	fset := token.NewFileSet()
+	updatedFile, err := parser.ParseFile(fset, "", updated, parser.ParseComments)
+	if err != nil {
+		return updated
+	}
+
+	// NB(mmihic): Comments are matched on their lines, ignoring order and blank
+	// lines. Comments whose lines appear in more than one form are ambiguous and
+	// left as printed.
+	originals := map[string]string{}
+	for _, cg := range origFile.Comments {
+		key, text := commentLines(cg)
+		if prev, ok := originals[key]; ok && prev != text {
+			text = ""
+		}
+		originals[key] = text
+	}
+
+	tokFile := fset.File(updatedFile.Pos())
+	for i := len(updatedFile.Comments) - 1; i >= 0; i-- {
+		cg := updatedFile.Comments[i]
+		if tokFile.Position(cg.Pos()).Column != 1 {
+			continue
+		}
+
+		key, text := commentLines(cg)
+		if original := originals[key]; original != "" && original != text {
+			start, end := tokFile.Offset(cg.Pos()), tokFile.Offset(cg.End())
+			updated = append(append(append([]byte{}, updated[:start]...), original...), updated[end:]...)
+		}
+	}
+
+	return updated
+}
+
+// commentLines returns a key identifying the non-blank lines of the comment
+// group regardless of their order, along with the text of the group.
+func commentLines(cg *ast.CommentGroup) (key, text string) {
+	var lines, nonBlank []string
+	for _, c := range cg.List {
+		lines = append(lines, c.Text)
+		if c.Text != "//" {
+			nonBlank = append(nonBlank, c.Text)
+		}
+	}
+
+	sort.Strings(nonBlank)
+	return strings.Join(nonBlank, "\n"), strings.Join(lines, "\n")
+}
+
+// usesCRLF returns true if most of the lines in the source end with \r\n.
+func usesCRLF(src []byte) bool {
+	numCRLF := bytes.Count(src, crlf)
+	return numCRLF > 0 && numCRLF >= bytes.Count(src, lf)-numCRLF
+}
+
+// writeAtomic writes the file by writing to a temporary file in the same
+// directory and renaming it over the original. If the original exists, its
+// mode and, where supported, ownership are preserved.
+func writeAtomic(fname string, src []byte, info os.FileInfo) (err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp")
 	if err != nil {
 		return err
 	}
 
 	defer func() {
-		_ = file.Close()
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
 	}()
 
-	return format.Node(file, fset, f)
+	mode := os.FileMode(0644)
+	if info != nil {
+		mode = info.Mode().Perm()
+		chown(tmp, info)
+	}
+
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(src); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fname)
 }
@@ -0,0 +1,111 @@
+package astio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSource(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		orig string
+		src  string
+		want string
+	}{
+		{
+			name: "new file",
+			src:  "package other\n",
+			want: "package other\n",
+		},
+		{
+			name: "keeps layout",
+			orig: "package first\n\nvar x   = 10 // x\n",
+			src:  "package other\n\nvar x = 10 // x\n",
+			want: "package other\n\nvar x   = 10 // x\n",
+		},
+		{
+			name: "keeps CRLF line endings",
+			orig: "package first\r\n\r\nimport (\r\n\t\"fmt\"\r\n)\r\n\r\nvar x = fmt.Sprint() // x\r\n",
+			src:  "package first\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n\nvar x = fmt.Sprint(os.Args) // x\n",
+			want: "package first\r\n\r\nimport (\r\n\t\"fmt\"\r\n\t\"os\"\r\n)\r\n\r\nvar x = fmt.Sprint(os.Args) // x\r\n",
+		},
+		{
+			name: "keeps cgo preamble and //export comments",
+			orig: "package first\r\n\r\n/*\r\n#cgo LDFLAGS: -lpng\r\n#include <png.h>\r\n*/\r\nimport \"C\"\r\n\r\n" +
+				"//export Add\r\n// Add adds.\r\nfunc Add(a, b C.int) C.int { return a + b }\r\n\r\n" +
+				"//export Sub\r\n//\r\nfunc Sub(a, b C.int) C.int { return a - b }\r\n",
+			src: "package other\n\n/*\n#cgo LDFLAGS: -lpng\n#include <png.h>\n*/\nimport \"C\"\n\n" +
+				"// Add adds.\n//\n//export Add\nfunc Add(a, b C.int) C.int { return a + b }\n\n" +
+				"//\n//export Sub\nfunc Sub(a, b C.int) C.int { return a - b }\n",
+			want: "package other\r\n\r\n/*\r\n#cgo LDFLAGS: -lpng\r\n#include <png.h>\r\n*/\r\nimport \"C\"\r\n\r\n" +
+				"//export Add\r\n// Add adds.\r\nfunc Add(a, b C.int) C.int { return a + b }\r\n\r\n" +
+				"//export Sub\r\n//\r\nfunc Sub(a, b C.int) C.int { return a - b }\r\n",
+		},
+		{
+			name: "separates import groups",
+			orig: "package first\r\n\r\nimport (\r\n\t\"fmt\"\r\n\t\"example.com/first\"\r\n)\r\n\r\nvar x   = fmt.Sprint(first.X)\r\n",
+			src:  "package first\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/other\"\n)\n\nvar x = fmt.Sprint(other.X)\n",
+			want: "package first\r\n\r\nimport (\r\n\t\"fmt\"\r\n\r\n\t\"example.com/other\"\r\n)\r\n\r\nvar x   = fmt.Sprint(other.X)\r\n",
+		},
+		{
+			name: "keeps layout of unchanged imports",
+			orig: "package first\n\nimport (\n\tf   \"fmt\"\n)\n\nvar x = f.Sprint(1)\n",
+			src:  "package first\n\nimport (\n\tf \"fmt\"\n)\n\nvar x = f.Sprint(2)\n",
+			want: "package first\n\nimport (\n\tf   \"fmt\"\n)\n\nvar x = f.Sprint(2)\n",
+		},
+		{
+			name: "keeps byte order mark",
+			orig: "\xef\xbb\xbfpackage first\n",
+			src:  "package other\n",
+			want: "\xef\xbb\xbfpackage other\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "astio")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.RemoveAll(dir)
+			}()
+
+			fname := filepath.Join(dir, "file.go")
+			if tt.orig != "" {
+				require.NoError(t, ioutil.WriteFile(fname, []byte(tt.orig), 0600))
+			}
+
+			require.NoError(t, WriteSource(fname, []byte(tt.src)))
+
+			contents, err := ioutil.ReadFile(fname)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(contents))
+
+			// Only the file itself should remain, with its original mode
+			files, err := ioutil.ReadDir(dir)
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			if tt.orig != "" {
+				assert.Equal(t, os.FileMode(0600), files[0].Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestWriteSource_InvalidSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astio")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	fname := filepath.Join(dir, "file.go")
+	require.NoError(t, ioutil.WriteFile(fname, []byte("package first\n"), 0644))
+	assert.Error(t, WriteSource(fname, []byte("package \"first\n")))
+
+	contents, err := ioutil.ReadFile(fname)
+	require.NoError(t, err)
+	assert.Equal(t, "package first\n", string(contents))
+}
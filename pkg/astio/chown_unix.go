@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package astio
+
+import (
+	"os"
+	"syscall"
+)
+
+// chown gives the file the same owner as the original, if possible.
+func chown(f *os.File, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		// NB(mmihic): Only privileged users can give files away, so this is
+		// best effort.
+		_ = f.Chown(int(stat.Uid), int(stat.Gid))
+	}
+}
@@ -0,0 +1,8 @@
+package astio
+
+import (
+	"os"
+)
+
+// chown is a no-op on windows, which has no file ownership in the unix sense.
+func chown(_ *os.File, _ os.FileInfo) {}
@@ -0,0 +1,168 @@
+package astio
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// A Transaction stages changes to files in memory, so that either all of them
+// or none of them are written. It is safe for concurrent use.
+type Transaction struct {
+	mu     sync.Mutex
+	staged map[string]*stagedFile
+}
+
+// NewTransaction creates a new, empty, Transaction.
+func NewTransaction() *Transaction {
+	return &Transaction{
+		staged: map[string]*stagedFile{},
+	}
+}
+
+// WriteFile stages the given file to be written on commit.
+func (tx *Transaction) WriteFile(fset *token.FileSet, f *ast.File) error {
+	src, err := Bytes(fset, f)
+	if err != nil {
+		return err
+	}
+
+	return tx.WriteSource(fset.File(f.Pos()).Name(), src)
+}
+
+// WriteSource stages the source to be written to the named file on commit,
+// in the same way as the package level WriteSource.
+func (tx *Transaction) WriteSource(fname string, src []byte) error {
+	return tx.write(fname, src, applyChanges)
+}
+
+// WriteBytes stages the contents to be written to the named file on commit.
+// Unlike WriteSource, the contents needn't be Go source, and replace the
+// original contents wholesale.
+func (tx *Transaction) WriteBytes(fname string, contents []byte) error {
+	return tx.write(fname, contents, func(_, updated []byte) ([]byte, error) {
+		return updated, nil
+	})
+}
+
+func (tx *Transaction) write(fname string, src []byte, merge func(orig, updated []byte) ([]byte, error)) error {
+	staged, err := stage(fname, src, merge)
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	// Files that end up unchanged don't need to be written
+	if staged.orig != nil && bytes.Equal(staged.orig, staged.src) {
+		delete(tx.staged, staged.fname)
+		return nil
+	}
+
+	// NB(mmihic): Restaging a file keeps its true original contents, so that
+	// rolling back restores the file as it was before the transaction.
+	if prev, ok := tx.staged[staged.fname]; ok {
+		staged.orig, staged.info = prev.orig, prev.info
+	}
+
+	tx.staged[staged.fname] = staged
+	return nil
+}
+
+// Files returns the names of the files staged to be written, sorted.
+func (tx *Transaction) Files() []string {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	fnames := make([]string, 0, len(tx.staged))
+	for fname := range tx.staged {
+		fnames = append(fnames, fname)
+	}
+
+	sort.Strings(fnames)
+	return fnames
+}
+
+// A Change is a change to a file staged in a transaction.
+type Change struct {
+	Filename string
+
+	// The contents of the file before and after the change. Before is nil
+	// for files that don't yet exist.
+	Before, After []byte
+}
+
+// Changes returns the changes staged in the transaction, sorted by filename.
+func (tx *Transaction) Changes() []*Change {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	changes := make([]*Change, 0, len(tx.staged))
+	for _, staged := range tx.staged {
+		changes = append(changes, &Change{
+			Filename: staged.fname,
+			Before:   staged.orig,
+			After:    staged.src,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Filename < changes[j].Filename
+	})
+	return changes
+}
+
+// Commit writes all of the staged files. If any file can't be written, the
+// files already written are restored to their original contents.
+func (tx *Transaction) Commit() error {
+	var written []*stagedFile
+	for _, fname := range tx.Files() {
+		tx.mu.Lock()
+		staged := tx.staged[fname]
+		tx.mu.Unlock()
+
+		if err := writeAtomic(staged.fname, staged.src, staged.info); err != nil {
+			err = fmt.Errorf("unable to write %s: %v", staged.fname, err)
+			if rollbackErr := rollback(written); rollbackErr != nil {
+				return multierr.Append(err, fmt.Errorf("unable to roll back: %v", rollbackErr))
+			}
+			return err
+		}
+
+		written = append(written, staged)
+	}
+
+	tx.Rollback()
+	return nil
+}
+
+// Rollback discards all of the staged files.
+func (tx *Transaction) Rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.staged = map[string]*stagedFile{}
+}
+
+// rollback restores the given files to their original contents, removing
+// those that were newly created.
+func rollback(written []*stagedFile) error {
+	var errs error
+	for _, staged := range written {
+		if staged.info == nil {
+			errs = multierr.Append(errs, os.Remove(staged.fname))
+			continue
+		}
+
+		errs = multierr.Append(errs, writeAtomic(staged.fname, staged.orig, staged.info))
+	}
+
+	return errs
+}
@@ -0,0 +1,69 @@
+package astio
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astio")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	first, second := filepath.Join(dir, "first.go"), filepath.Join(dir, "second.go")
+	require.NoError(t, ioutil.WriteFile(first, []byte("package first\n"), 0644))
+
+	tx := NewTransaction()
+	require.NoError(t, tx.WriteSource(first, []byte("package other\n")))
+	require.NoError(t, tx.WriteSource(second, []byte("package other\n")))
+	assert.Equal(t, []string{first, second}, tx.Files())
+
+	// Nothing is written until commit
+	contents, err := ioutil.ReadFile(first)
+	require.NoError(t, err)
+	assert.Equal(t, "package first\n", string(contents))
+	_, err = os.Stat(second)
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, tx.Commit())
+	for _, fname := range []string{first, second} {
+		contents, err := ioutil.ReadFile(fname)
+		require.NoError(t, err)
+		assert.Equal(t, "package other\n", string(contents))
+	}
+}
+
+func TestTransaction_RollsBackOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "astio")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	first, second := filepath.Join(dir, "first.go"), filepath.Join(dir, "second.go")
+	require.NoError(t, ioutil.WriteFile(first, []byte("package first\n"), 0644))
+
+	tx := NewTransaction()
+	require.NoError(t, tx.WriteSource(first, []byte("package other\n")))
+	require.NoError(t, tx.WriteSource(second, []byte("package other\n")))
+
+	// Make the second file impossible to write
+	require.NoError(t, os.MkdirAll(filepath.Join(second, "nested"), 0755))
+
+	assert.Error(t, tx.Commit())
+
+	contents, err := ioutil.ReadFile(first)
+	require.NoError(t, err)
+	assert.Equal(t, "package first\n", string(contents))
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
@@ -0,0 +1,269 @@
+// Package buildtags classifies files by their build constraints.
+package buildtags
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"path/filepath"
+	"strings"
+)
+
+// Constraints are the build constraints of a file, from its //go:build or
+// // +build lines and from its name.
+type Constraints struct {
+	// Expr is the //go:build expression, or the // +build lines joined with
+	// &&, empty if the file has neither.
+	Expr string
+
+	// GOOS and GOARCH are implied by the file name, as in foo_linux_amd64.go.
+	GOOS   string
+	GOARCH string
+}
+
+// Parse returns the build constraints of the given file.
+func Parse(fname string, f *ast.File) Constraints {
+	var comments []string
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+
+		for _, c := range cg.List {
+			comments = append(comments, c.Text)
+		}
+	}
+
+	return newConstraints(fname, comments)
+}
+
+// ParseSource returns the build constraints of the named source file, which
+// need not be Go source; assembly files have build constraints too.
+func ParseSource(fname string, src []byte) Constraints {
+	var comments []string
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+
+		comments = append(comments, line)
+	}
+
+	return newConstraints(fname, comments)
+}
+
+func newConstraints(fname string, comments []string) Constraints {
+	c := Constraints{Expr: parseExpr(comments)}
+	c.GOOS, c.GOARCH = parseFilename(filepath.Base(fname))
+	return c
+}
+
+// String returns the constraints as a single build expression, or an empty
+// string if the file is unconstrained.
+func (c Constraints) String() string {
+	var terms []string
+	if c.Expr != "" {
+		terms = append(terms, c.Expr)
+	}
+
+	if c.GOOS != "" {
+		terms = append(terms, c.GOOS)
+	}
+
+	if c.GOARCH != "" {
+		terms = append(terms, c.GOARCH)
+	}
+
+	if len(terms) > 1 && strings.Contains(c.Expr, "||") {
+		terms[0] = "(" + terms[0] + ")"
+	}
+
+	return strings.Join(terms, " && ")
+}
+
+// Ignored returns true if the file is excluded from the build with the ignore
+// tag, as is conventional for generators run with go run. The file is only
+// ignored if it can't build without the ignore tag, whatever other tags are set;
+// !ignore, or ignore || linux, don't exclude it.
+func (c Constraints) Ignored() bool {
+	if c.Expr == "" {
+		return false
+	}
+
+	expr, err := constraint.Parse("//go:build " + c.Expr)
+	if err != nil {
+		return false
+	}
+
+	return !canBe(expr, true)
+}
+
+// canBe returns true if the build expression can take the given value with
+// the ignore tag unset, whatever other tags are set.
+//
+// NB(mmihic): Each occurrence of a tag is treated as independent of the
+// others, which keeps this linear in the size of the expression. This can
+// only find an expression satisfiable when it isn't, as with linux && !linux,
+// so a file is never wrongly treated as ignored.
+func canBe(expr constraint.Expr, value bool) bool {
+	switch expr := expr.(type) {
+	case *constraint.TagExpr:
+		return expr.Tag != "ignore" || !value
+	case *constraint.NotExpr:
+		return canBe(expr.X, !value)
+	case *constraint.AndExpr:
+		if value {
+			return canBe(expr.X, true) && canBe(expr.Y, true)
+		}
+		return canBe(expr.X, false) || canBe(expr.Y, false)
+	case *constraint.OrExpr:
+		if value {
+			return canBe(expr.X, true) || canBe(expr.Y, true)
+		}
+		return canBe(expr.X, false) && canBe(expr.Y, false)
+	}
+
+	return true
+}
+
+// parseExpr extracts the build expression from the comments at the head of a
+// file.
+func parseExpr(comments []string) string {
+	// NB(mmihic): A //go:build line takes precedence over // +build lines.
+	var plusBuild []string
+	for _, text := range comments {
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, "//go:build ") {
+			return strings.TrimSpace(text[len("//go:build "):])
+		}
+
+		if strings.HasPrefix(text, "// +build ") {
+			plusBuild = append(plusBuild, plusBuildExpr(text[len("// +build "):]))
+		}
+	}
+
+	if len(plusBuild) > 1 {
+		for i, expr := range plusBuild {
+			if strings.Contains(expr, "||") {
+				plusBuild[i] = "(" + expr + ")"
+			}
+		}
+	}
+
+	return strings.Join(plusBuild, " && ")
+}
+
+// plusBuildExpr converts a // +build line to a build expression. Space
+// separated options are or'd, comma separated terms and'd.
+func plusBuildExpr(line string) string {
+	var options []string
+	for _, option := range strings.Fields(line) {
+		options = append(options, strings.Join(strings.Split(option, ","), " && "))
+	}
+
+	if len(options) > 1 {
+		for i, option := range options {
+			if strings.Contains(option, "&&") {
+				options[i] = "(" + option + ")"
+			}
+		}
+	}
+
+	return strings.Join(options, " || ")
+}
+
+// parseFilename returns the GOOS and GOARCH implied by the file name.
+func parseFilename(name string) (goos, goarch string) {
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	name = strings.TrimSuffix(name, "_test")
+
+	// NB(mmihic): As with the go tool, the first element is never a
+	// constraint, so linux.go applies to all platforms.
+	elems := strings.Split(name, "_")
+	if len(elems) < 2 {
+		return "", ""
+	}
+
+	last := elems[len(elems)-1]
+	if len(elems) >= 3 && knownOS[elems[len(elems)-2]] && knownArch[last] {
+		return elems[len(elems)-2], last
+	}
+
+	if knownOS[last] {
+		return last, ""
+	}
+
+	if knownArch[last] {
+		return "", last
+	}
+
+	return "", ""
+}
+
+// A Platform is an operating system and architecture for which files are built.
+type Platform struct {
+	GOOS   string
+	GOARCH string
+}
+
+// ParsePlatform parses a platform of the form GOOS/GOARCH, as listed by go
+// tool dist list.
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || !knownOS[parts[0]] || !knownArch[parts[1]] {
+		return Platform{}, fmt.Errorf("invalid platform %s, must be GOOS/GOARCH", s)
+	}
+
+	return Platform{GOOS: parts[0], GOARCH: parts[1]}, nil
+}
+
+// String returns the platform in the form GOOS/GOARCH.
+func (p Platform) String() string {
+	return p.GOOS + "/" + p.GOARCH
+}
+
+// Match returns true if the named file in the given directory builds for any
+// of the platforms with the given tags.
+func Match(platforms []Platform, tags []string, dir, name string) (bool, error) {
+	for _, p := range platforms {
+		ctxt := build.Default
+		ctxt.GOOS, ctxt.GOARCH = p.GOOS, p.GOARCH
+		ctxt.BuildTags = tags
+
+		// NB(mmihic): cgo files should be rewritten whether or not a C toolchain
+		// happens to be available.
+		ctxt.CgoEnabled = true
+
+		matched, err := ctxt.MatchFile(dir, name)
+		if err != nil {
+			return false, err
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+	"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+	"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+	"windows": true, "zos": true,
+}
+
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+	"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true,
+	"mips64le": true, "mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+	"ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true,
+	"sparc": true, "sparc64": true, "wasm": true,
+}
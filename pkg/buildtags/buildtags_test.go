@@ -0,0 +1,181 @@
+package buildtags
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		fname   string
+		src     string
+		want    string
+		ignored bool
+	}{
+		{
+			name:  "unconstrained",
+			fname: "linux.go",
+			src:   "package first\n",
+		},
+		{
+			name:  "go:build line",
+			fname: "first.go",
+			src:   "//go:build linux || darwin\n// +build linux darwin\n\npackage first\n",
+			want:  "linux || darwin",
+		},
+		{
+			name:  "+build lines",
+			fname: "first.go",
+			src:   "// +build linux,amd64 darwin\n// +build !cgo\n\npackage first\n",
+			want:  "((linux && amd64) || darwin) && !cgo",
+		},
+		{
+			name:  "file name",
+			fname: "dir/first_windows_arm64_test.go",
+			src:   "package first\n",
+			want:  "windows && arm64",
+		},
+		{
+			name:  "file name and line",
+			fname: "first_amd64.go",
+			src:   "//go:build linux || darwin\n\npackage first\n",
+			want:  "(linux || darwin) && amd64",
+		},
+		{
+			name:    "ignored generator",
+			fname:   "gen.go",
+			src:     "// Copyright notice\n\n//go:build ignore\n\npackage main\n",
+			want:    "ignore",
+			ignored: true,
+		},
+		{
+			name:  "not ignored",
+			fname: "first.go",
+			src:   "//go:build !ignore\n\npackage first\n\n//go:build ignore\n",
+			want:  "!ignore",
+		},
+		{
+			name:    "ignored along with other tags",
+			fname:   "gen.go",
+			src:     "//go:build ignore && (linux || !cgo)\n\npackage main\n",
+			want:    "ignore && (linux || !cgo)",
+			ignored: true,
+		},
+		{
+			name:    "ignored with +build lines",
+			fname:   "gen.go",
+			src:     "// +build ignore\n// +build linux darwin\n\npackage main\n",
+			want:    "ignore && (linux || darwin)",
+			ignored: true,
+		},
+		{
+			name:  "ignore not required",
+			fname: "first.go",
+			src:   "//go:build ignore || linux\n\npackage first\n",
+			want:  "ignore || linux",
+		},
+		{
+			name:  "ignore not required when a tag is unset",
+			fname: "first.go",
+			src:   "//go:build ignore || !purego\n\npackage first\n",
+			want:  "ignore || !purego",
+		},
+		{
+			name:    "many other tags",
+			fname:   "first.go",
+			src:     "//go:build ignore && (" + manyTags + ")\n\npackage first\n",
+			want:    "ignore && (" + manyTags + ")",
+			ignored: true,
+		},
+		{
+			name:  "similar tag",
+			fname: "first.go",
+			src:   "//go:build ignore_windows\n\npackage first\n",
+			want:  "ignore_windows",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), tt.fname, tt.src, parser.ParseComments)
+			require.NoError(t, err)
+
+			c := Parse(tt.fname, f)
+			assert.Equal(t, tt.want, c.String())
+			assert.Equal(t, tt.ignored, c.Ignored())
+		})
+	}
+}
+
+// manyTags is a build expression with more tags than can be enumerated.
+var manyTags = func() string {
+	var tags []string
+	for i := 0; i < 80; i++ {
+		tags = append(tags, fmt.Sprintf("tag%d", i))
+	}
+	return strings.Join(tags, " || ")
+}()
+
+func TestParseSource(t *testing.T) {
+	src := "// Copyright notice\n\n//go:build !purego\n\n#include \"textflag.h\"\n\n// +build ignore\n"
+	assert.Equal(t, "!purego && amd64", ParseSource("add_amd64.s", []byte(src)).String())
+	assert.Equal(t, "", ParseSource("add.s", []byte("#include \"textflag.h\"\n")).String())
+}
+
+func TestParsePlatform(t *testing.T) {
+	p, err := ParsePlatform("linux/arm64")
+	require.NoError(t, err)
+	assert.Equal(t, Platform{GOOS: "linux", GOARCH: "arm64"}, p)
+	assert.Equal(t, "linux/arm64", p.String())
+
+	for _, s := range []string{"linux", "linux/", "beos/amd64", "linux/amd64/v2"} {
+		_, err := ParsePlatform(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "buildtags")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	for name, src := range map[string]string{
+		"first.go":         "package first\n",
+		"first_windows.go": "package first\n",
+		"first_unix.go":    "//go:build linux || darwin\n\npackage first\n",
+		"tagged.go":        "//go:build extra\n\npackage first\n",
+		"gen.go":           "//go:build ignore\n\npackage main\n",
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644))
+	}
+
+	linux := []Platform{{GOOS: "linux", GOARCH: "amd64"}}
+	both := append(linux, Platform{GOOS: "windows", GOARCH: "amd64"})
+	for _, tt := range []struct {
+		platforms []Platform
+		tags      []string
+		name      string
+		want      bool
+	}{
+		{linux, nil, "first.go", true},
+		{linux, nil, "first_windows.go", false},
+		{both, nil, "first_windows.go", true},
+		{linux, nil, "first_unix.go", true},
+		{linux, nil, "tagged.go", false},
+		{linux, []string{"extra"}, "tagged.go", true},
+		{both, nil, "gen.go", false},
+		{linux, []string{"ignore"}, "gen.go", true},
+	} {
+		matched, err := Match(tt.platforms, tt.tags, dir, tt.name)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, matched, "%s %v %v", tt.name, tt.platforms, tt.tags)
+	}
+}
@@ -0,0 +1,159 @@
+// Package filter selects the directories and files to process, following the
+// same conventions as the go tool along with configurable globs.
+package filter
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/buildtags"
+)
+
+// IgnoredDir returns true if the go tool ignores directories with the given
+// name when matching packages: vendor, testdata, and names starting with a dot
+// or underscore.
+func IgnoredDir(name string) bool {
+	return name == "vendor" || name == "testdata" ||
+		strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// A Filter selects directories and files by path, relative to the directory
+// being processed. Globs use path.Match syntax, with ** matching any number of
+// directories. Globs without a slash match the base name anywhere in the tree.
+type Filter struct {
+	// Include, if not empty, restricts processing to the files matching one of
+	// the globs.
+	Include []string `yaml:"include,omitempty"`
+
+	// Exclude skips the directories and files matching any of the globs.
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// Vendor descends into vendor directories rather than skipping them.
+	Vendor bool `yaml:"vendor,omitempty"`
+
+	// Platforms, if not empty, restricts processing to the files that build for
+	// one of the platforms, given as GOOS/GOARCH, with the build tags in Tags.
+	// Otherwise files are processed whatever their build constraints.
+	Platforms []string `yaml:"platforms,omitempty"`
+	Tags      []string `yaml:"tags,omitempty"`
+}
+
+// Validate checks that all of the globs and platforms are well formed.
+func (f *Filter) Validate() error {
+	if _, err := f.platforms(); err != nil {
+		return err
+	}
+
+	for _, glob := range append(append([]string{}, f.Include...), f.Exclude...) {
+		for _, elem := range strings.Split(filepath.ToSlash(glob), "/") {
+			if _, err := path.Match(elem, ""); err != nil {
+				return fmt.Errorf("invalid glob %s: %v", glob, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SkipDir returns true if the directory at the given relative path should be
+// skipped along with its contents.
+func (f *Filter) SkipDir(rel string) bool {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." {
+		return false
+	}
+
+	if base := path.Base(rel); IgnoredDir(base) && !(f.Vendor && base == "vendor") {
+		return true
+	}
+
+	return matchAny(f.Exclude, rel)
+}
+
+// SkipFile returns true if the file at the given relative path should be
+// skipped.
+func (f *Filter) SkipFile(rel string) bool {
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if matchAny(f.Exclude, rel) {
+		return true
+	}
+
+	return len(f.Include) != 0 && !matchAny(f.Include, rel)
+}
+
+// SkipBuild returns true if the named file in the given directory doesn't
+// build for any of the platforms.
+func (f *Filter) SkipBuild(dir, name string) bool {
+	if len(f.Platforms) == 0 {
+		return false
+	}
+
+	platforms, err := f.platforms()
+	if err != nil {
+		return false
+	}
+
+	// NB(mmihic): Files whose constraints can't be read are kept, so that the
+	// error is reported when they are parsed.
+	matched, err := buildtags.Match(platforms, f.Tags, dir, name)
+	return err == nil && !matched
+}
+
+func (f *Filter) platforms() ([]buildtags.Platform, error) {
+	var platforms []buildtags.Platform
+	for _, s := range f.Platforms {
+		p, err := buildtags.ParsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+func matchAny(globs []string, rel string) bool {
+	for _, glob := range globs {
+		if matched, _ := match(glob, rel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// match matches the relative path against the glob.
+func match(glob, rel string) (bool, error) {
+	glob = strings.Trim(filepath.ToSlash(glob), "/")
+	if !strings.Contains(glob, "/") {
+		return path.Match(glob, path.Base(rel))
+	}
+
+	return matchElems(strings.Split(glob, "/"), strings.Split(rel, "/"))
+}
+
+func matchElems(glob, elems []string) (bool, error) {
+	for len(glob) != 0 {
+		if glob[0] == "**" {
+			// Try matching the rest of the glob at every remaining depth
+			for i := 0; i <= len(elems); i++ {
+				if matched, err := matchElems(glob[1:], elems[i:]); matched || err != nil {
+					return matched, err
+				}
+			}
+			return false, nil
+		}
+
+		if len(elems) == 0 {
+			return false, nil
+		}
+
+		matched, err := path.Match(glob[0], elems[0])
+		if !matched || err != nil {
+			return false, err
+		}
+
+		glob, elems = glob[1:], elems[1:]
+	}
+
+	return len(elems) == 0, nil
+}
@@ -0,0 +1,65 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_SkipDir(t *testing.T) {
+	f := &Filter{Exclude: []string{"node_modules", "internal/generated/**", "docs/*/examples"}}
+	for _, tt := range []struct {
+		rel  string
+		want bool
+	}{
+		{".", false},
+		{"pkg/first", false},
+		{"vendor", true},
+		{"pkg/first/testdata", true},
+		{"_examples", true},
+		{".git", true},
+		{"web/node_modules", true},
+		{"internal/generated", true},
+		{"internal/generated/nested/deeper", true},
+		{"internal/other", false},
+		{"docs/api/examples", true},
+		{"docs/api/more/examples", false},
+	} {
+		assert.Equal(t, tt.want, f.SkipDir(tt.rel), tt.rel)
+	}
+
+	vendor := &Filter{Vendor: true}
+	assert.False(t, vendor.SkipDir("vendor"))
+	assert.False(t, vendor.SkipDir("sub/vendor/github.com/other/lib"))
+	assert.True(t, vendor.SkipDir("vendor/github.com/other/lib/testdata"))
+}
+
+func TestFilter_SkipFile(t *testing.T) {
+	f := &Filter{
+		Include: []string{"pkg/**/*.go", "main.go"},
+		Exclude: []string{"*_gen.go"},
+	}
+	for _, tt := range []struct {
+		rel  string
+		want bool
+	}{
+		{"main.go", false},
+		{"cmd/main.go", false},
+		{"pkg/first.go", false},
+		{"pkg/first/nested/first.go", false},
+		{"pkg/first/nested/first_gen.go", true},
+		{"cmd/other.go", true},
+	} {
+		assert.Equal(t, tt.want, f.SkipFile(tt.rel), tt.rel)
+	}
+
+	assert.False(t, (&Filter{}).SkipFile("anything/at/all.go"))
+}
+
+func TestFilter_Validate(t *testing.T) {
+	assert.NoError(t, (&Filter{Include: []string{"**/*.go"}, Exclude: []string{"a/[bc]/d"}}).Validate())
+	assert.Error(t, (&Filter{Exclude: []string{"a/[b"}}).Validate())
+	assert.Error(t, (&Filter{Include: []string{"[b"}}).Validate())
+	assert.NoError(t, (&Filter{Platforms: []string{"linux/amd64", "windows/arm64"}}).Validate())
+	assert.Error(t, (&Filter{Platforms: []string{"linux"}}).Validate())
+}
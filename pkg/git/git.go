@@ -0,0 +1,176 @@
+// Package git integrates with git repositories, using the git command line.
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// A Repo is a git working tree.
+type Repo struct {
+	// Root is the absolute path to the top level of the working tree.
+	Root string
+}
+
+// Open opens the repository containing the given directory.
+func Open(dir string) (*Repo, error) {
+	out, err := run(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %v", dir, err)
+	}
+
+	return &Repo{Root: strings.TrimSpace(string(out))}, nil
+}
+
+// Dirty returns the paths, relative to the root, that have uncommitted changes
+// or are untracked and not ignored, other than the excepted paths. These may be
+// absolute or relative to the current directory.
+func (r *Repo) Dirty(except ...string) ([]string, error) {
+	out, err := r.git(nil, "status", "--porcelain", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	excepted := map[string]bool{}
+	for _, p := range except {
+		rel, err := r.rel(p)
+		if err != nil {
+			return nil, err
+		}
+		excepted[filepath.ToSlash(rel)] = true
+	}
+
+	var dirty []string
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+
+		if !excepted[entry[3:]] {
+			dirty = append(dirty, entry[3:])
+		}
+
+		// Renames and copies are followed by the original path
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+
+	return dirty, nil
+}
+
+// Ignored returns a function that reports whether a path is ignored by git.
+// Paths may be absolute or relative to the current directory.
+func (r *Repo) Ignored() (func(path string) bool, error) {
+	out, err := r.git(nil, "ls-files", "--others", "--ignored", "--exclude-standard", "--directory", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := map[string]bool{}
+	for _, p := range strings.Split(string(out), "\x00") {
+		if p != "" {
+			ignored[strings.TrimSuffix(p, "/")] = true
+		}
+	}
+
+	return func(path string) bool {
+		rel, err := r.rel(path)
+		if err != nil {
+			return false
+		}
+
+		// Ignored directories are listed once, rather than by their contents
+		for ; rel != "." && rel != "/"; rel = filepath.Dir(rel) {
+			if ignored[filepath.ToSlash(rel)] {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// Add stages the given paths, which may be absolute or relative to the current
+// directory.
+func (r *Repo) Add(paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	abs, err := absPaths(paths)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.git(nil, append([]string{"add", "--"}, abs...)...)
+	return err
+}
+
+func absPaths(paths []string) ([]string, error) {
+	abs := make([]string, len(paths))
+	for i, p := range paths {
+		var err error
+		if abs[i], err = filepath.Abs(p); err != nil {
+			return nil, err
+		}
+	}
+	return abs, nil
+}
+
+// Commit commits the given paths, which may be absolute or relative to the
+// current directory, with the given message. Any other staged changes are left
+// staged rather than swept into the commit.
+func (r *Repo) Commit(message string, paths ...string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	abs, err := absPaths(paths)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.git(strings.NewReader(message), append([]string{"commit", "--quiet", "--file", "-", "--"}, abs...)...)
+	return err
+}
+
+// rel returns the path relative to the root of the repository.
+func (r *Repo) rel(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// NB(mmihic): git reports the root with symlinks resolved
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+
+	return filepath.Rel(r.Root, abs)
+}
+
+func (r *Repo) git(stdin *strings.Reader, args ...string) ([]byte, error) {
+	return run(r.Root, stdin, args...)
+}
+
+func run(dir string, stdin *strings.Reader, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRepo(t *testing.T, files map[string]string) (*Repo, func()) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "git")
+	require.NoError(t, err)
+	cleanup := func() {
+		_ = os.RemoveAll(dir)
+	}
+
+	for fname, contents := range files {
+		fname = filepath.Join(dir, fname)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0755))
+		require.NoError(t, ioutil.WriteFile(fname, []byte(contents), 0644))
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"config", "user.name", "Test"},
+		{"config", "user.email", "test@example.com"},
+		{"add", "."},
+		{"commit", "--quiet", "-m", "initial"},
+	} {
+		_, err := run(dir, nil, args...)
+		require.NoError(t, err)
+	}
+
+	repo, err := Open(filepath.Join(dir, "pkg"))
+	require.NoError(t, err)
+	return repo, cleanup
+}
+
+func TestRepo(t *testing.T) {
+	repo, cleanup := testRepo(t, map[string]string{
+		".gitignore":        "build/\n*.gen.go\n",
+		"pkg/first/a.go":    "package first\n",
+		"pkg/first/b.go":    "package first\n",
+		"build/out/main.go": "package main\n",
+		"pkg/x.gen.go":      "package pkg\n",
+	})
+	defer cleanup()
+
+	dirty, err := repo.Dirty()
+	require.NoError(t, err)
+	assert.Empty(t, dirty)
+
+	ignored, err := repo.Ignored()
+	require.NoError(t, err)
+	assert.True(t, ignored(filepath.Join(repo.Root, "build")))
+	assert.True(t, ignored(filepath.Join(repo.Root, "build/out/main.go")))
+	assert.True(t, ignored(filepath.Join(repo.Root, "pkg/x.gen.go")))
+	assert.False(t, ignored(filepath.Join(repo.Root, "pkg/first/a.go")))
+	assert.False(t, ignored(repo.Root))
+
+	// Modify and commit, leaving an untracked file behind
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo.Root, "pkg/first/a.go"), []byte("package other\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo.Root, "journal.yaml"), []byte("files: []\n"), 0644))
+	dirty, err = repo.Dirty()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg/first/a.go", "journal.yaml"}, dirty)
+
+	dirty, err = repo.Dirty(filepath.Join(repo.Root, "journal.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg/first/a.go"}, dirty)
+
+	// Changes staged beforehand aren't committed along with the given paths
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repo.Root, "pkg/first/b.go"), []byte("package staged\n"), 0644))
+	require.NoError(t, repo.Add(filepath.Join(repo.Root, "pkg/first/b.go")))
+
+	require.NoError(t, repo.Add(filepath.Join(repo.Root, "pkg/first/a.go")))
+	require.NoError(t, repo.Commit("Rename first to other\n", filepath.Join(repo.Root, "pkg/first/a.go")))
+
+	dirty, err = repo.Dirty(filepath.Join(repo.Root, "journal.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pkg/first/b.go"}, dirty)
+
+	out, err := run(repo.Root, nil, "log", "--format=%s", "--name-status", "-1")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Rename first to other")
+	assert.Contains(t, strings.Replace(string(out), "\t", " ", -1), "M pkg/first/a.go")
+	assert.NotContains(t, string(out), "pkg/first/b.go")
+
+	// The earlier change is still staged
+	out, err = run(repo.Root, nil, "diff", "--cached", "--name-only")
+	require.NoError(t, err)
+	assert.Equal(t, "pkg/first/b.go\n", string(out))
+}
@@ -0,0 +1,101 @@
+package ident
+
+import (
+	"go/token"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+var (
+	reMajorVersion  = regexp.MustCompile(`^v[0-9]+$`)
+	reVersionSuffix = regexp.MustCompile(`\.v[0-9]+$`)
+
+	predeclared = map[string]struct{}{
+		// Types
+		"any": {}, "bool": {}, "byte": {}, "comparable": {}, "complex64": {}, "complex128": {},
+		"error": {}, "float32": {}, "float64": {}, "int": {}, "int8": {}, "int16": {},
+		"int32": {}, "int64": {}, "rune": {}, "string": {}, "uint": {}, "uint8": {},
+		"uint16": {}, "uint32": {}, "uint64": {}, "uintptr": {},
+
+		// Constants and zero value
+		"true": {}, "false": {}, "iota": {}, "nil": {},
+
+		// Functions
+		"append": {}, "cap": {}, "clear": {}, "close": {}, "complex": {}, "copy": {},
+		"delete": {}, "imag": {}, "len": {}, "make": {}, "max": {}, "min": {}, "new": {},
+		"panic": {}, "print": {}, "println": {}, "real": {}, "recover": {},
+	}
+)
+
+const (
+	// prefix used when an identifier would otherwise start with a digit or be empty
+	invalidPrefix = "pkg"
+
+	// suffix used when an identifier would otherwise be a keyword or predeclared
+	reservedSuffix = "pkg"
+)
+
+// Clean produces a clean identifier. Characters that cannot appear in an
+// identifier are removed, and the result is adjusted so that it is always a
+// valid identifier that is neither a keyword nor a predeclared identifier.
+func Clean(ident string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, ident)
+
+	if cleaned == "" || unicode.IsDigit([]rune(cleaned)[0]) {
+		cleaned = invalidPrefix + cleaned
+	}
+
+	if IsReserved(cleaned) {
+		cleaned += reservedSuffix
+	}
+
+	return cleaned
+}
+
+// IsReserved returns true if the name is a keyword or a predeclared identifier,
+// and so should not be used as the name of an import.
+func IsReserved(name string) bool {
+	if token.IsKeyword(name) {
+		return true
+	}
+
+	_, isPredeclared := predeclared[name]
+	return isPredeclared
+}
+
+// TrimMajorVersion removes a trailing major version element, as in
+// github.com/foo/bar/v2, from the import path.
+func TrimMajorVersion(importPath path.Path) path.Path {
+	if len(importPath) > 1 && reMajorVersion.MatchString(importPath[len(importPath)-1]) {
+		return importPath[:len(importPath)-1]
+	}
+
+	return importPath
+}
+
+// FromImportPath synthesizes the identifier by which the package at the given
+// import path is conventionally known, following the same conventions as the
+// go tools: major version elements (foo/v2) and suffixes (gopkg.in/yaml.v2)
+// and go- prefixes (go-foo) are ignored.
+func FromImportPath(importPath path.Path) string {
+	importPath = TrimMajorVersion(importPath)
+	if len(importPath) == 0 {
+		return Clean("")
+	}
+
+	name := importPath[len(importPath)-1]
+	name = reVersionSuffix.ReplaceAllString(name, "")
+	if trimmed := strings.TrimPrefix(name, "go-"); trimmed != "" {
+		name = trimmed
+	}
+
+	return Clean(name)
+}
@@ -0,0 +1,52 @@
+package ident
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestClean(t *testing.T) {
+	for _, tt := range []struct {
+		ident string
+		want  string
+	}{
+		{"other", "other"},
+		{"this-other", "thisother"},
+		{"this_other", "this_other"},
+		{"café", "café"},
+		{"日本", "日本"},
+		{"123abc", "pkg123abc"},
+		{"", "pkg"},
+		{"---", "pkg"},
+		{"type", "typepkg"},
+		{"func", "funcpkg"},
+		{"string", "stringpkg"},
+		{"error", "errorpkg"},
+		{"nil", "nilpkg"},
+		{"len", "lenpkg"},
+	} {
+		assert.Equal(t, tt.want, Clean(tt.ident), tt.ident)
+	}
+}
+
+func TestFromImportPath(t *testing.T) {
+	for _, tt := range []struct {
+		importPath string
+		want       string
+	}{
+		{"github.com/mmihic/go-tools/pkg/ident", "ident"},
+		{"github.com/mmihic/go-tools", "tools"},
+		{"github.com/foo/bar/v2", "bar"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"github.com/foo/this-other", "thisother"},
+		{"github.com/foo/pkg/123", "pkg123"},
+		{"github.com/foo/pkg/type", "typepkg"},
+		{"github.com/foo/go-", "gopkg"},
+		{"v2", "v2"},
+	} {
+		assert.Equal(t, tt.want, FromImportPath(path.NewPath(tt.importPath)), tt.importPath)
+	}
+}
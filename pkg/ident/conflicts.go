@@ -3,20 +3,11 @@ package ident
 
 import (
 	"go/ast"
-	"path/filepath"
-	"regexp"
 	"strconv"
-)
 
-var (
-	reNotIdent = regexp.MustCompile(`[^A-Za-z0-9_]`)
+	"github.com/mmihic/go-tools/pkg/path"
 )
 
-// Clean produces a clean identifier.
-func Clean(ident string) string {
-	return reNotIdent.ReplaceAllString(ident, "")
-}
-
 // HasConflict checks whether the given name conflicts with any
 // types or declarations in the given node tree.
 func HasConflict(root ast.Node, potentialName string, skip func(n ast.Node) bool) bool {
@@ -71,8 +62,8 @@ func (d *conflictDetector) Visit(nth ast.Node) ast.Visitor {
 	case *ast.TypeSpec:
 		d.checkConflict(n.Name)
 	case *ast.ImportSpec:
-		path, _ := strconv.Unquote(n.Path.Value)
-		_, importName := filepath.Split(path)
+		importPath, _ := strconv.Unquote(n.Path.Value)
+		importName := FromImportPath(path.NewPath(importPath))
 		if n.Name != nil {
 			importName = n.Name.Name
 		}
@@ -0,0 +1,116 @@
+package imports
+
+import (
+	"github.com/mmihic/go-tools/pkg/ident"
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// An AliasPolicy decides the names under which packages are imported.
+type AliasPolicy interface {
+	// Candidates returns the names to try for an import of the given path, most
+	// preferred first. The package name is the name declared by the package, or
+	// assumed from its path if unresolved. The existing alias is the explicit
+	// name under which the package was imported before being rewritten, or ""
+	// if there was none.
+	Candidates(importPath path.Path, pkgName, existingAlias string) []string
+
+	// NumericSuffixes returns true if names with numeric suffixes, such as
+	// other2, can be used when all of the candidates conflict.
+	NumericSuffixes() bool
+
+	// KeepAlias returns true if an import of the given path that has the given
+	// alias should keep it, even where the name of the package would do.
+	KeepAlias(importPath path.Path, alias string) bool
+}
+
+// DefaultAliasPolicy tries the name of the package, then the name of the
+// package combined with its parent unless the parent is a generic name such as
+// pkg or internal, and finally falls back to numeric suffixes.
+var DefaultAliasPolicy AliasPolicy = &AliasConfig{}
+
+// AliasConfig is an AliasPolicy loaded from configuration.
+type AliasConfig struct {
+	// Aliases maps import paths to the alias they should always be imported
+	// under, for example k8s.io/apimachinery/pkg/apis/meta/v1 to metav1.
+	Aliases map[string]string `yaml:"aliases"`
+
+	// KeepExisting keeps the alias an import already had, if it does not conflict.
+	KeepExisting bool `yaml:"keep_existing"`
+
+	// PreferParent tries the combination of the parent and package name before
+	// the package name alone.
+	PreferParent bool `yaml:"prefer_parent"`
+
+	// AllowNumericSuffixes allows numeric suffixes when all other names conflict.
+	// Defaults to true. When false, names combining more of the import path
+	// are tried instead.
+	AllowNumericSuffixes *bool `yaml:"numeric_suffixes"`
+}
+
+// Candidates returns the names to try for an import of the given path.
+func (cfg *AliasConfig) Candidates(importPath path.Path, pkgName, existingAlias string) []string {
+	var candidates []string
+	if alias, ok := cfg.Aliases[importPath.String()]; ok {
+		candidates = append(candidates, alias)
+	}
+
+	if cfg.KeepExisting && existingAlias != "" && existingAlias != "_" && existingAlias != "." {
+		candidates = append(candidates, existingAlias)
+	}
+
+	trimmedPath := ident.TrimMajorVersion(importPath)
+	if len(trimmedPath) < 2 {
+		return uniqueNames(append(candidates, pkgName))
+	}
+
+	// NB(mmihic): Generic parents like [pkg, internal, src, etc] add nothing
+	// to the name, so we don't bother combining with them.
+	parent := trimmedPath[len(trimmedPath)-2]
+	if _, commonPkgName := commonPkgNames[ident.Clean(parent)]; commonPkgName {
+		candidates = append(candidates, pkgName)
+	} else if comboPkgName := ident.Clean(parent + pkgName); cfg.PreferParent {
+		candidates = append(candidates, comboPkgName, pkgName)
+	} else {
+		candidates = append(candidates, pkgName, comboPkgName)
+	}
+
+	if !cfg.NumericSuffixes() {
+		// Without numeric suffixes, keep prepending ancestors (ignoring the host)
+		// until the name is unique.
+		name := pkgName
+		for i := len(trimmedPath) - 2; i > 0; i-- {
+			name = ident.Clean(trimmedPath[i] + name)
+			candidates = append(candidates, name)
+		}
+	}
+
+	return uniqueNames(candidates)
+}
+
+func uniqueNames(names []string) []string {
+	seen := make(map[string]struct{}, len(names))
+	unique := names[:0]
+	for _, name := range names {
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// NumericSuffixes returns true if numeric suffixes are allowed.
+func (cfg *AliasConfig) NumericSuffixes() bool {
+	return cfg.AllowNumericSuffixes == nil || *cfg.AllowNumericSuffixes
+}
+
+// KeepAlias returns true if existing aliases are kept, or the alias is the one
+// configured for the path.
+func (cfg *AliasConfig) KeepAlias(importPath path.Path, alias string) bool {
+	if cfg.KeepExisting {
+		return true
+	}
+
+	configured, ok := cfg.Aliases[importPath.String()]
+	return ok && configured == alias
+}
@@ -0,0 +1,95 @@
+package imports
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestAliasConfig_Candidates(t *testing.T) {
+	noSuffixes := false
+
+	for _, tt := range []struct {
+		name          string
+		cfg           *AliasConfig
+		importPath    string
+		existingAlias string
+		want          []string
+	}{
+		{
+			name:       "default",
+			cfg:        &AliasConfig{},
+			importPath: "github.com/mmihic/go-tools/pkg/other",
+			want:       []string{"other"},
+		},
+		{
+			name:       "combines with parent",
+			cfg:        &AliasConfig{},
+			importPath: "github.com/foo/services/config",
+			want:       []string{"config", "servicesconfig"},
+		},
+		{
+			name:       "configured alias",
+			cfg:        &AliasConfig{Aliases: map[string]string{"k8s.io/api/core/v1": "corev1"}},
+			importPath: "k8s.io/api/core/v1",
+			want:       []string{"corev1", "core", "apicore"},
+		},
+		{
+			name:          "ignores existing alias by default",
+			cfg:           &AliasConfig{},
+			importPath:    "github.com/foo/services/config",
+			existingAlias: "svcconfig",
+			want:          []string{"config", "servicesconfig"},
+		},
+		{
+			name:          "keeps existing alias",
+			cfg:           &AliasConfig{KeepExisting: true},
+			importPath:    "github.com/foo/services/config",
+			existingAlias: "svcconfig",
+			want:          []string{"svcconfig", "config", "servicesconfig"},
+		},
+		{
+			name:          "never keeps blank or dot imports",
+			cfg:           &AliasConfig{KeepExisting: true},
+			importPath:    "github.com/foo/services/config",
+			existingAlias: "_",
+			want:          []string{"config", "servicesconfig"},
+		},
+		{
+			name:       "prefers parent",
+			cfg:        &AliasConfig{PreferParent: true},
+			importPath: "github.com/foo/services/config",
+			want:       []string{"servicesconfig", "config"},
+		},
+		{
+			name:       "without numeric suffixes",
+			cfg:        &AliasConfig{AllowNumericSuffixes: &noSuffixes},
+			importPath: "github.com/foo/src/services/config",
+			want:       []string{"config", "servicesconfig", "srcservicesconfig", "foosrcservicesconfig"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			importPath := path.NewPath(tt.importPath)
+			assert.Equal(t, tt.want, tt.cfg.Candidates(importPath, PkgName(importPath), tt.existingAlias))
+		})
+	}
+}
+
+func TestDisambiguateImportNameFunc_Policy(t *testing.T) {
+	noSuffixes := false
+	namer := NewNamer(nil, &AliasConfig{AllowNumericSuffixes: &noSuffixes})
+
+	taken := map[string]bool{"config": true, "servicesconfig": true}
+	name, err := namer.DisambiguateImportNameFunc(path.NewPath("github.com/foo/services/config"), "",
+		func(name string) bool { return taken[name] })
+	if assert.NoError(t, err) {
+		assert.Equal(t, "fooservicesconfig", name)
+	}
+
+	taken["fooservicesconfig"] = true
+	_, err = namer.DisambiguateImportNameFunc(path.NewPath("github.com/foo/services/config"), "",
+		func(name string) bool { return taken[name] })
+	assert.Error(t, err)
+}
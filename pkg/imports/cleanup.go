@@ -0,0 +1,218 @@
+package imports
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/astutil"
+
+	"github.com/mmihic/go-tools/pkg/ident"
+)
+
+// An Index resolves identifiers to the declarations they refer to. The
+// scope.Index satisfies this interface.
+type Index interface {
+	// Resolve returns the declaration to which the identifier refers, or nil.
+	Resolve(ident *ast.Ident) ast.Node
+
+	// References returns the identifiers referring to the given declaration.
+	References(decl ast.Node) []*ast.Ident
+}
+
+// Cleanup tidies the given imports of the file, as left behind by moves
+// rewriting them. It removes those that are unused, merges those that duplicate
+// another import of the same path, rewriting references to use a single name,
+// and drops their aliases where the alias is the same as the name of the
+// imported package and the alias policy doesn't keep it. Other imports are left
+// as they are. The index must have been built from the file as given. Returns
+// true if the file was changed.
+func Cleanup(fset *token.FileSet, f *ast.File, idx Index, rewritten []*ast.ImportSpec) bool {
+	return (*Namer)(nil).Cleanup(fset, f, idx, rewritten)
+}
+
+// Cleanup is like the Cleanup function, but names imports with the Namer and
+// consults its alias policy.
+func (n *Namer) Cleanup(fset *token.FileSet, f *ast.File, idx Index, rewritten []*ast.ImportSpec) bool {
+	changed := false
+	hasUnresolved := hasUnresolvedQualifiers(f, idx)
+
+	isRewritten := make(map[*ast.ImportSpec]bool, len(rewritten))
+	for _, imp := range rewritten {
+		isRewritten[imp] = true
+	}
+
+	// Remove unused imports, including blank imports that duplicate a used import
+	var (
+		remaining []*ast.ImportSpec
+		byPath    = map[string][]*ast.ImportSpec{}
+	)
+
+	// NB(mmihic): Deleting imports modifies f.Imports, so work from a copy.
+	for _, imp := range append([]*ast.ImportSpec{}, f.Imports...) {
+		if !isRewritten[imp] || !n.isUnused(imp, idx, hasUnresolved) {
+			remaining = append(remaining, imp)
+			byPath[imp.Path.Value] = append(byPath[imp.Path.Value], imp)
+			continue
+		}
+
+		deleteImport(fset, f, imp)
+		changed = true
+	}
+
+	for _, imp := range remaining {
+		if !isRewritten[imp] || imp.Name == nil || imp.Name.Name != "_" || len(byPath[imp.Path.Value]) == 1 {
+			continue
+		}
+
+		deleteImport(fset, f, imp)
+		byPath[imp.Path.Value] = removeImport(byPath[imp.Path.Value], imp)
+		changed = true
+	}
+
+	// Merge rewritten duplicates into an import of the same path, preferring one
+	// that was already there so its references keep their name
+	merged := map[string]bool{}
+	for _, imp := range remaining {
+		dups := byPath[imp.Path.Value]
+		if merged[imp.Path.Value] || len(dups) < 2 {
+			continue
+		}
+		merged[imp.Path.Value] = true
+
+		keep := mergeTarget(dups, isRewritten)
+		if keep == nil {
+			continue
+		}
+
+		name := n.Name(keep)
+		for _, dup := range dups {
+			if dup == keep || !isRewritten[dup] || isSpecialImport(dup) || !n.canRename(f, dup, name) {
+				continue
+			}
+
+			for _, ref := range idx.References(dup) {
+				ref.Name = name
+			}
+
+			deleteImport(fset, f, dup)
+			changed = true
+		}
+	}
+
+	// Drop redundant aliases
+	for _, imp := range f.Imports {
+		if !isRewritten[imp] || imp.Name == nil || isSpecialImport(imp) {
+			continue
+		}
+
+		importPath := Path(imp)
+		if n.RequiresAlias(importPath, imp.Name.Name) || n.AliasPolicy().KeepAlias(importPath, imp.Name.Name) {
+			continue
+		}
+
+		imp.Name = nil
+		changed = true
+	}
+
+	return changed
+}
+
+// mergeTarget returns the import of a path into which its rewritten duplicates
+// are merged: the first that wasn't rewritten, or failing that the first, as
+// long as it is referenced by name.
+func mergeTarget(dups []*ast.ImportSpec, isRewritten map[*ast.ImportSpec]bool) *ast.ImportSpec {
+	var target *ast.ImportSpec
+	for _, imp := range dups {
+		if isSpecialImport(imp) {
+			continue
+		}
+
+		if !isRewritten[imp] {
+			return imp
+		}
+
+		if target == nil {
+			target = imp
+		}
+	}
+
+	return target
+}
+
+// isSpecialImport returns true for blank, dot and cgo imports, which are not
+// referenced by name.
+func isSpecialImport(imp *ast.ImportSpec) bool {
+	if IsCgo(imp) {
+		return true
+	}
+
+	return imp.Name != nil && (imp.Name.Name == "_" || imp.Name.Name == ".")
+}
+
+// isUnused returns true if the import is never referenced.
+func (n *Namer) isUnused(imp *ast.ImportSpec, idx Index, hasUnresolved bool) bool {
+	if isSpecialImport(imp) || len(idx.References(imp)) != 0 {
+		return false
+	}
+
+	// NB(mmihic): If we don't know the name of the package, it may be referenced
+	// under a name other than the one we assume. Only treat it as unused if all of
+	// the qualifiers in the file can be accounted for.
+	if _, ok := n.LookupPkgName(Path(imp)); imp.Name == nil && !ok && hasUnresolved {
+		return false
+	}
+
+	return true
+}
+
+// hasUnresolvedQualifiers returns true if any selector in the file is qualified
+// by an identifier that does not resolve to a declaration.
+func hasUnresolvedQualifiers(f *ast.File, idx Index) bool {
+	hasUnresolved := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && idx.Resolve(x) == nil {
+				hasUnresolved = true
+			}
+		}
+		return !hasUnresolved
+	})
+	return hasUnresolved
+}
+
+// canRename returns true if the references to the duplicate import can safely
+// be renamed to the given name. An alias the alias policy keeps is never
+// renamed. This is conservative: any other declaration of the name anywhere in
+// the file prevents the rename.
+func (n *Namer) canRename(f *ast.File, dup *ast.ImportSpec, name string) bool {
+	if n.Name(dup) == name {
+		return true
+	}
+
+	if dup.Name != nil && n.AliasPolicy().KeepAlias(Path(dup), dup.Name.Name) {
+		return false
+	}
+
+	return !ident.HasConflict(f, name, func(decl ast.Node) bool {
+		other, ok := decl.(*ast.ImportSpec)
+		return ok && other.Path.Value == dup.Path.Value
+	})
+}
+
+// deleteImport deletes the given import spec from the file.
+func deleteImport(fset *token.FileSet, f *ast.File, imp *ast.ImportSpec) {
+	// NB(mmihic): astutil deletes every import matching the name and path, so give
+	// the spec a name no other import can have to delete exactly this one.
+	imp.Name = &ast.Ident{Name: "__deleted__", NamePos: imp.Path.Pos()}
+	astutil.DeleteNamedImport(fset, f, imp.Name.Name, importPathValue(imp))
+}
+
+func removeImport(specs []*ast.ImportSpec, imp *ast.ImportSpec) []*ast.ImportSpec {
+	var remaining []*ast.ImportSpec
+	for _, spec := range specs {
+		if spec != imp {
+			remaining = append(remaining, spec)
+		}
+	}
+	return remaining
+}
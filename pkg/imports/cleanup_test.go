@@ -0,0 +1,352 @@
+package imports_test
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/imports"
+	"github.com/mmihic/go-tools/pkg/path"
+	"github.com/mmihic/go-tools/pkg/scope"
+)
+
+func TestCleanup(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "removes unused imports",
+			src: `
+package main
+
+import (
+	"fmt"
+	other "github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/unused"
+	_ "github.com/mmihic/go-tools/pkg/sideeffects"
+)
+
+func main() { fmt.Println() }
+`,
+			want: `
+package main
+
+import (
+	"fmt"
+	_ "github.com/mmihic/go-tools/pkg/sideeffects"
+)
+
+func main() { fmt.Println() }
+`,
+		},
+		{
+			name: "keeps unresolved imports if qualifiers are unaccounted for",
+			src: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/go-other"
+)
+
+func main() { other.Do() }
+`,
+			want: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/go-other"
+)
+
+func main() { other.Do() }
+`,
+		},
+		{
+			name: "merges duplicate imports",
+			src: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/other"
+	first "github.com/mmihic/go-tools/pkg/other"
+	_ "github.com/mmihic/go-tools/pkg/other"
+)
+
+func main() { other.Do(first.Do()) }
+`,
+			want: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/other"
+)
+
+func main() { other.Do(other.Do()) }
+`,
+		},
+		{
+			name: "does not merge when the name is declared elsewhere",
+			src: `
+package main
+
+import (
+	first "github.com/mmihic/go-tools/pkg/other"
+	second "github.com/mmihic/go-tools/pkg/other"
+)
+
+func main() { first.Do() }
+
+func other() {
+	first := 10
+	second.Do(first)
+}
+`,
+			want: `
+package main
+
+import (
+	first "github.com/mmihic/go-tools/pkg/other"
+	second "github.com/mmihic/go-tools/pkg/other"
+)
+
+func main() { first.Do() }
+
+func other() {
+	first := 10
+	second.Do(first)
+}
+`,
+		},
+		{
+			name: "drops redundant aliases",
+			src: `
+package main
+
+import (
+	other "github.com/mmihic/go-tools/pkg/other"
+	secondother "github.com/mmihic/go-tools/pkg/second/other"
+	. "github.com/mmihic/go-tools/pkg/dot"
+)
+
+func main() { other.Do(secondother.Do(Dot())) }
+`,
+			want: `
+package main
+
+import (
+	. "github.com/mmihic/go-tools/pkg/dot"
+	"github.com/mmihic/go-tools/pkg/other"
+	secondother "github.com/mmihic/go-tools/pkg/second/other"
+)
+
+func main() { other.Do(secondother.Do(Dot())) }
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "", tt.src, parser.ParseComments)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			imports.Cleanup(fset, file, scope.NewIndex(file), file.Imports)
+
+			results, err := astio.String(fset, file)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, strings.TrimSpace(tt.want), strings.TrimSpace(results))
+		})
+	}
+}
+
+func TestCleanup_ResolvedNames(t *testing.T) {
+	namer := imports.NewNamer(imports.PkgNameResolverFunc(func(importPath path.Path) (string, bool) {
+		switch importPath.String() {
+		case "github.com/mmihic/go-tools/pkg/go-other":
+			return "other", true
+		case "github.com/mmihic/go-tools/pkg/unused":
+			return "unused", true
+		default:
+			return "", false
+		}
+	}), nil)
+
+	src := `
+package main
+
+import (
+	other "github.com/mmihic/go-tools/pkg/go-other"
+	"github.com/mmihic/go-tools/pkg/go-unused"
+	"github.com/mmihic/go-tools/pkg/unused"
+)
+
+func main() { other.Do(mystery.Do()) }
+`
+
+	want := `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/go-other"
+	"github.com/mmihic/go-tools/pkg/go-unused"
+)
+
+func main() { other.Do(mystery.Do()) }
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, namer.Cleanup(fset, file, scope.NewIndexNamed(file, namer), file.Imports))
+
+	results, err := astio.String(fset, file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, strings.TrimSpace(want), strings.TrimSpace(results))
+}
+
+func TestCleanup_OnlyRewrittenImports(t *testing.T) {
+	src := `
+package main
+
+import (
+	"fmt"
+	other "github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/unused"
+	third "github.com/mmihic/go-tools/pkg/third"
+	rewritten "github.com/mmihic/go-tools/pkg/third"
+	"github.com/mmihic/go-tools/pkg/rewrittenunused"
+)
+
+func main() { fmt.Println(other.Do(third.Do(rewritten.Do()))) }
+`
+
+	// NB(mmihic): Only the imports the moves rewrote are cleaned up, the rest
+	// are left as the author wrote them, unused and redundant as they may be.
+	want := `
+package main
+
+import (
+	"fmt"
+	other "github.com/mmihic/go-tools/pkg/other"
+	third "github.com/mmihic/go-tools/pkg/third"
+	"github.com/mmihic/go-tools/pkg/unused"
+)
+
+func main() { fmt.Println(other.Do(third.Do(third.Do()))) }
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, imports.Cleanup(fset, file, scope.NewIndex(file), file.Imports[4:]))
+
+	results, err := astio.String(fset, file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, strings.TrimSpace(want), strings.TrimSpace(results))
+}
+
+func TestCleanup_AliasPolicy(t *testing.T) {
+	src := `
+package main
+
+import (
+	other "github.com/mmihic/go-tools/pkg/other"
+	v1 "github.com/mmihic/go-tools/pkg/meta/v1"
+	"github.com/mmihic/go-tools/pkg/third"
+	legacy "github.com/mmihic/go-tools/pkg/third"
+)
+
+func main() { other.Do(v1.Do(third.Do(legacy.Do()))) }
+`
+
+	for _, tt := range []struct {
+		name   string
+		policy *imports.AliasConfig
+		want   string
+	}{
+		{
+			name:   "default",
+			policy: &imports.AliasConfig{},
+			want: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/meta/v1"
+	"github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/third"
+)
+
+func main() { other.Do(v1.Do(third.Do(third.Do()))) }
+`,
+		},
+		{
+			name:   "configured alias",
+			policy: &imports.AliasConfig{Aliases: map[string]string{"github.com/mmihic/go-tools/pkg/meta/v1": "v1"}},
+			want: `
+package main
+
+import (
+	v1 "github.com/mmihic/go-tools/pkg/meta/v1"
+	"github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/third"
+)
+
+func main() { other.Do(v1.Do(third.Do(third.Do()))) }
+`,
+		},
+		{
+			name:   "keep existing",
+			policy: &imports.AliasConfig{KeepExisting: true},
+			want: `
+package main
+
+import (
+	v1 "github.com/mmihic/go-tools/pkg/meta/v1"
+	other "github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/third"
+	legacy "github.com/mmihic/go-tools/pkg/third"
+)
+
+func main() { other.Do(v1.Do(third.Do(legacy.Do()))) }
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			namer := imports.NewNamer(nil, tt.policy)
+			namer.Cleanup(fset, file, scope.NewIndexNamed(file, namer), file.Imports)
+
+			results, err := astio.String(fset, file)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, strings.TrimSpace(tt.want), strings.TrimSpace(results))
+		})
+	}
+}
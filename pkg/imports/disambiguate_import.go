@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"go/ast"
 	"strconv"
+	"strings"
 
 	"github.com/mmihic/go-tools/pkg/ident"
 	"github.com/mmihic/go-tools/pkg/path"
@@ -17,9 +18,14 @@ var (
 	}
 )
 
+// DisambiguateImportName finds a non-conflicting name for the given import path,
+// as named by a nil Namer.
+func DisambiguateImportName(root ast.Node, importPath path.Path) (string, error) {
+	return (*Namer)(nil).DisambiguateImportName(root, importPath)
+}
 
 // DisambiguateImportName finds a non-conflicting name for the given import path.
-func DisambiguateImportName(root ast.Node, importPath path.Path) string {
+func (n *Namer) DisambiguateImportName(root ast.Node, importPath path.Path) (string, error) {
 	// Ignore conflicts with an import of ourselves
 	skipSelf := func(n ast.Node) bool {
 		imp, ok := n.(*ast.ImportSpec)
@@ -30,36 +36,42 @@ func DisambiguateImportName(root ast.Node, importPath path.Path) string {
 		return imp.Path.Value == strconv.Quote(importPath.String())
 	}
 
-	// First try the name itself
-	pkgName := ident.Clean(importPath.PkgName())
-	if !ident.HasConflict(root, pkgName, skipSelf) {
-		return pkgName
-	}
+	return n.DisambiguateImportNameFunc(importPath, "", func(name string) bool {
+		return ident.HasConflict(root, name, skipSelf)
+	})
+}
 
-	// Next try a combination of our name + the parent name, if the parent is not a generic
-	// name like [pkg, internal, src, etc]
-	if len(importPath) > 1 {
-		parentPkgName := ident.Clean(importPath[len(importPath)-2])
-		if _, commonPkgName := commonPkgNames[parentPkgName]; !commonPkgName {
-			comboPkgName := parentPkgName + pkgName
-			if !ident.HasConflict(root, comboPkgName, skipSelf) {
-				return comboPkgName
-			}
+// DisambiguateImportNameFunc finds a name for the given import path for which
+// hasConflict returns false, consulting the Namer's AliasPolicy for the names
+// to try. The existing alias is the explicit name under which the package was
+// previously imported, if any.
+func (n *Namer) DisambiguateImportNameFunc(
+	importPath path.Path, existingAlias string, hasConflict func(name string) bool,
+) (string, error) {
+	policy := n.AliasPolicy()
+	pkgName := n.PkgName(importPath)
+
+	// First try the candidates suggested by the policy
+	candidates := policy.Candidates(importPath, pkgName, existingAlias)
+	for _, candidate := range candidates {
+		if !hasConflict(candidate) {
+			return candidate, nil
 		}
 	}
 
+	if !policy.NumericSuffixes() {
+		return "", fmt.Errorf("unable to find a name for import %s: %s all conflict",
+			importPath, strings.Join(candidates, ", "))
+	}
+
 	// Now start appending numbers to the package name until we find one that works
-	n := 2
+	i := 2
 	for {
-		importName := fmt.Sprintf("%s%d", pkgName, n)
-		if !ident.HasConflict(root, importName, skipSelf) {
-			return importName
+		importName := fmt.Sprintf("%s%d", pkgName, i)
+		if !hasConflict(importName) {
+			return importName, nil
 		}
 
-		n++
+		i++
 	}
-
 }
-
-
-
@@ -131,6 +131,64 @@ const other = 100
 `,
 			want: "mmihictoolsother",
 		},
+		{
+			name:    "ignores major version suffix",
+			pkgPath: "github.com/mmihic/other/v2",
+			src: `
+package whatever
+`,
+			want: "other",
+		},
+		{
+			name:    "ignores major version suffix when combining with parent",
+			pkgPath: "github.com/mmihic/other/v2",
+			src: `
+package whatever
+
+const other = 100
+`,
+			want: "mmihicother",
+		},
+		{
+			name:    "ignores gopkg.in version suffix",
+			pkgPath: "gopkg.in/yaml.v2",
+			src: `
+package whatever
+`,
+			want: "yaml",
+		},
+		{
+			name:    "strips go- prefix",
+			pkgPath: "github.com/mattn/go-sqlite3",
+			src: `
+package whatever
+`,
+			want: "sqlite3",
+		},
+		{
+			name:    "avoids leading digits",
+			pkgPath: "github.com/pkg/123",
+			src: `
+package whatever
+`,
+			want: "pkg123",
+		},
+		{
+			name:    "avoids keywords",
+			pkgPath: "github.com/pkg/type",
+			src: `
+package whatever
+`,
+			want: "typepkg",
+		},
+		{
+			name:    "avoids predeclared identifiers",
+			pkgPath: "github.com/mmihic/string",
+			src: `
+package whatever
+`,
+			want: "stringpkg",
+		},
 	} {
 		t.Run(tt.name, func(_ *testing.T) {
 			fset := token.NewFileSet()
@@ -139,7 +197,11 @@ const other = 100
 				return
 			}
 
-			name := DisambiguateImportName(file, path.NewPath(tt.pkgPath))
+			name, err := DisambiguateImportName(file, path.NewPath(tt.pkgPath))
+			if !assert.NoError(t, err) {
+				return
+			}
+
 			assert.Equal(t, tt.want, name)
 		})
 	}
@@ -5,16 +5,13 @@ import (
 	"go/ast"
 	"strconv"
 
-	"github.com/mmihic/go-tools/pkg/ident"
 	"github.com/mmihic/go-tools/pkg/path"
 )
 
-// Name returns the name of the import.
+// Name returns the name of the import as named by a nil Namer. For imports
+// without an explicit name this is the name assumed from the import path.
 func Name(imp *ast.ImportSpec) string {
-	if imp.Name != nil {
-		return imp.Name.Name
-	}
-	return ident.Clean(Path(imp).PkgName())
+	return (*Namer)(nil).Name(imp)
 }
 
 // Path returns the path of the import.
@@ -22,3 +19,9 @@ func Path(imp *ast.ImportSpec) path.Path {
 	val, _ := strconv.Unquote(imp.Path.Value)
 	return path.NewPath(val)
 }
+
+// IsCgo returns true if the import is cgo's pseudo-import "C", which must never
+// be renamed or moved away from its preamble.
+func IsCgo(imp *ast.ImportSpec) bool {
+	return imp.Path.Value == `"C"`
+}
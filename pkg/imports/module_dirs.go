@@ -0,0 +1,160 @@
+package imports
+
+import (
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// moduleDirs finds the directories holding the source of imported packages,
+// looking only at the files the go tool would read for a build, and never
+// running the go tool itself.
+type moduleDirs struct {
+	// root is the directory containing go.mod, or empty outside of a module
+	root string
+
+	// mainPath is the module path of the main module
+	mainPath string
+
+	// mods are the module paths required by the main module, mapped to
+	// the directory holding their source
+	mods map[string]string
+
+	gopath []string
+}
+
+// newModuleDirs creates a new moduleDirs for the module enclosing the given
+// directory. Outside of a module packages are found in GOROOT and GOPATH.
+func newModuleDirs(dir string) *moduleDirs {
+	d := &moduleDirs{
+		mods:   map[string]string{},
+		gopath: filepath.SplitList(build.Default.GOPATH),
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return d
+	}
+
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if data, err := ioutil.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			d.root = dir
+			d.load(filepath.Join(dir, "go.mod"), data)
+			return d
+		}
+
+		if filepath.Dir(dir) == dir {
+			return d
+		}
+	}
+}
+
+func (d *moduleDirs) load(fname string, data []byte) {
+	f, err := modfile.Parse(fname, data, nil)
+	if err != nil {
+		return
+	}
+
+	if f.Module != nil {
+		d.mainPath = f.Module.Mod.Path
+	}
+
+	for _, req := range f.Require {
+		d.mods[req.Mod.Path] = d.cacheDir(req.Mod)
+	}
+
+	for _, rep := range f.Replace {
+		if _, required := d.mods[rep.Old.Path]; !required {
+			continue
+		}
+
+		if rep.New.Version == "" {
+			// NB(mmihic): Replacements without a version are directories,
+			// relative to the main module if not absolute.
+			dir := filepath.FromSlash(rep.New.Path)
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(d.root, dir)
+			}
+			d.mods[rep.Old.Path] = dir
+			continue
+		}
+
+		d.mods[rep.Old.Path] = d.cacheDir(rep.New)
+	}
+}
+
+// cacheDir returns the directory holding the given module version in the
+// module cache, or empty if it can't be determined.
+func (d *moduleDirs) cacheDir(mod module.Version) string {
+	cache := os.Getenv("GOMODCACHE")
+	if cache == "" {
+		if len(d.gopath) == 0 {
+			return ""
+		}
+		cache = filepath.Join(d.gopath[0], "pkg", "mod")
+	}
+
+	escPath, err := module.EscapePath(mod.Path)
+	if err != nil {
+		return ""
+	}
+
+	escVersion, err := module.EscapeVersion(mod.Version)
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(cache, filepath.FromSlash(escPath)+"@"+escVersion)
+}
+
+// Dirs returns the directories that may hold the package at the given import
+// path, in the order the go tool would look for them.
+func (d *moduleDirs) Dirs(importPath string) []string {
+	var dirs []string
+	if d.root != "" {
+		dirs = append(dirs, filepath.Join(d.root, "vendor", filepath.FromSlash(importPath)))
+	}
+
+	if build.Default.GOROOT != "" {
+		dirs = append(dirs, filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(importPath)))
+	}
+
+	if d.root != "" && hasPathPrefix(importPath, d.mainPath) {
+		dirs = append(dirs, filepath.Join(d.root, filepath.FromSlash(strings.TrimPrefix(importPath, d.mainPath))))
+	}
+
+	// NB(mmihic): The module providing a package is the required module with the
+	// longest path that is a prefix of the import path.
+	var modPath string
+	for p := range d.mods {
+		if hasPathPrefix(importPath, p) && len(p) > len(modPath) {
+			modPath = p
+		}
+	}
+
+	if modDir := d.mods[modPath]; modDir != "" {
+		dirs = append(dirs, filepath.Join(modDir, filepath.FromSlash(strings.TrimPrefix(importPath, modPath))))
+	}
+
+	if d.root == "" {
+		for _, gopath := range d.gopath {
+			dirs = append(dirs, filepath.Join(gopath, "src", filepath.FromSlash(importPath)))
+		}
+	}
+
+	return dirs
+}
+
+// hasPathPrefix returns true if the import path is the prefix or lies below it.
+func hasPathPrefix(importPath, prefix string) bool {
+	if prefix == "" || !strings.HasPrefix(importPath, prefix) {
+		return false
+	}
+
+	return len(importPath) == len(prefix) || importPath[len(prefix)] == '/'
+}
@@ -0,0 +1,80 @@
+package imports
+
+import (
+	"go/ast"
+
+	"github.com/mmihic/go-tools/pkg/ident"
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// A Namer names imports, using a resolver for the names declared by imported
+// packages and a policy for the aliases under which they are imported. A nil
+// Namer resolves no packages, assuming their names from their import paths,
+// and uses the DefaultAliasPolicy. A Namer is safe for concurrent use if its
+// resolver is.
+type Namer struct {
+	pkgNames PkgNameResolver
+	aliases  AliasPolicy
+}
+
+// NewNamer creates a new Namer with the given resolver and alias policy, either
+// of which may be nil to use the defaults.
+func NewNamer(pkgNames PkgNameResolver, aliases AliasPolicy) *Namer {
+	return &Namer{pkgNames: pkgNames, aliases: aliases}
+}
+
+// AliasPolicy returns the policy used to choose the names of imports.
+func (n *Namer) AliasPolicy() AliasPolicy {
+	if n == nil || n.aliases == nil {
+		return DefaultAliasPolicy
+	}
+
+	return n.aliases
+}
+
+// LookupPkgName returns the name of the package at the given import path, or
+// false if the package could not be resolved.
+func (n *Namer) LookupPkgName(importPath path.Path) (string, bool) {
+	// NB(mmihic): cgo's pseudo-package has no source, but is always known as C
+	if importPath.Equal(cgoPath) {
+		return "C", true
+	}
+
+	if n == nil || n.pkgNames == nil {
+		return "", false
+	}
+
+	return n.pkgNames.PkgName(importPath)
+}
+
+// PkgName returns the name of the package at the given import path, falling
+// back to the name conventionally assumed from the path if the package could
+// not be resolved.
+func (n *Namer) PkgName(importPath path.Path) string {
+	if name, ok := n.LookupPkgName(importPath); ok {
+		return name
+	}
+
+	return ident.FromImportPath(importPath)
+}
+
+// RequiresAlias returns true if an import of the given path needs an explicit
+// alias to be known by the given name. When the package can't be resolved,
+// an alias is only omitted if the name matches the last element of the path.
+func (n *Namer) RequiresAlias(importPath path.Path, name string) bool {
+	if pkgName, ok := n.LookupPkgName(importPath); ok {
+		return name != pkgName
+	}
+
+	return name != importPath.PkgName()
+}
+
+// Name returns the name of the import. For imports without an explicit name
+// this is the name of the imported package.
+func (n *Namer) Name(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+
+	return n.PkgName(Path(imp))
+}
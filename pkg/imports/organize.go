@@ -0,0 +1,214 @@
+package imports
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// An ImportGroup is a group of imports, separated from other groups by a
+// blank line.
+type ImportGroup int
+
+// Import groups, in the order in which they appear.
+const (
+	StdGroup ImportGroup = iota
+	ThirdPartyGroup
+	LocalGroup
+)
+
+// Organizer regroups and sorts imports in the style of goimports: standard
+// library imports first, then third party imports, then local imports.
+type Organizer struct {
+	// LocalPrefixes are the import path prefixes of local packages, as with
+	// goimports -local.
+	LocalPrefixes []path.Path
+}
+
+// NewOrganizer creates a new Organizer treating packages under any of the
+// given prefixes as local.
+func NewOrganizer(localPrefixes ...string) *Organizer {
+	o := &Organizer{}
+	for _, prefix := range localPrefixes {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			o.LocalPrefixes = append(o.LocalPrefixes, path.NewPath(prefix))
+		}
+	}
+	return o
+}
+
+// Group returns the group to which an import of the given path belongs.
+func (o *Organizer) Group(importPath path.Path) ImportGroup {
+	for _, prefix := range o.LocalPrefixes {
+		if prefix.Contains(importPath) {
+			return LocalGroup
+		}
+	}
+
+	// NB(mmihic): Same heuristic as goimports - only standard library
+	// packages lack a dot in their first element.
+	if len(importPath) == 0 || !strings.Contains(importPath[0], ".") {
+		return StdGroup
+	}
+
+	return ThirdPartyGroup
+}
+
+// Organize regroups and sorts the imports of the given source, returning the
+// formatted result. Import blocks that can't be safely reordered, such as
+// those containing the cgo import "C" or specs sharing a line, are left as is.
+func (o *Organizer) Organize(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse source: %v", err)
+	}
+
+	tokFile := fset.File(f.Pos())
+
+	// Rewrite each block from the end, so that earlier offsets remain valid
+	var out = src
+	for i := len(f.Decls) - 1; i >= 0; i-- {
+		decl, ok := f.Decls[i].(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT || !decl.Lparen.IsValid() {
+			continue
+		}
+
+		start := tokFile.Offset(lineStart(tokFile, tokFile.Line(decl.Lparen)+1))
+		end := tokFile.Offset(lineStart(tokFile, tokFile.Line(decl.Rparen)))
+		if start >= end {
+			continue
+		}
+
+		block, ok := o.organizeBlock(fset, f, decl, src, start, end)
+		if !ok {
+			continue
+		}
+
+		out = append(append(append([]byte{}, out[:start]...), block...), out[end:]...)
+	}
+
+	return format.Source(out)
+}
+
+// importLines are the source lines for an import spec, including its comments.
+type importLines struct {
+	spec  *ast.ImportSpec
+	group ImportGroup
+	text  []byte
+}
+
+func (o *Organizer) organizeBlock(
+	fset *token.FileSet, f *ast.File, decl *ast.GenDecl, src []byte, start, end int,
+) ([]byte, bool) {
+	tokFile := fset.File(f.Pos())
+
+	var (
+		imports   []*importLines
+		prevLine  = tokFile.Line(decl.Lparen)
+		prevEnd   = start
+		pending   []byte
+		blockLine = tokFile.Line(decl.Rparen)
+	)
+
+	for _, s := range decl.Specs {
+		spec := s.(*ast.ImportSpec)
+		if IsCgo(spec) {
+			// NB(mmihic): The preamble must stay directly above import "C"
+			return nil, false
+		}
+
+		specStart, specEnd := spec.Pos(), spec.End()
+		if spec.Doc != nil {
+			specStart = spec.Doc.Pos()
+		}
+		if spec.Comment != nil {
+			specEnd = spec.Comment.End()
+		}
+
+		firstLine, lastLine := tokFile.Line(specStart), tokFile.Line(specEnd)
+		if firstLine <= prevLine || lastLine >= blockLine {
+			// Specs sharing a line with each other or with the parens
+			return nil, false
+		}
+
+		// NB(mmihic): Anything between the previous spec and this one is
+		// either blank or a floating comment, which we keep with this spec.
+		between := bytes.TrimSpace(src[prevEnd:tokFile.Offset(lineStart(tokFile, firstLine))])
+		if len(between) != 0 {
+			pending = append(append(pending, between...), '\n')
+		}
+
+		textEnd := tokFile.Offset(lineStart(tokFile, lastLine+1))
+		text := append(pending, bytes.TrimSpace(src[tokFile.Offset(lineStart(tokFile, firstLine)):textEnd])...)
+		pending = nil
+
+		imports = append(imports, &importLines{
+			spec:  spec,
+			group: o.Group(Path(spec)),
+			text:  append(text, '\n'),
+		})
+
+		prevLine, prevEnd = lastLine, textEnd
+	}
+
+	// Trailing floating comments stay at the end of the block
+	trailing := bytes.TrimSpace(src[prevEnd:end])
+
+	sort.SliceStable(imports, func(i, j int) bool {
+		a, b := imports[i], imports[j]
+		if a.group != b.group {
+			return a.group < b.group
+		}
+
+		if a.spec.Path.Value != b.spec.Path.Value {
+			return importPathValue(a.spec) < importPathValue(b.spec)
+		}
+
+		return importName(a.spec) < importName(b.spec)
+	})
+
+	var buf bytes.Buffer
+	for i, imp := range imports {
+		if i != 0 && imp.group != imports[i-1].group {
+			buf.WriteByte('\n')
+		}
+		buf.Write(imp.text)
+	}
+
+	if len(trailing) != 0 {
+		buf.Write(trailing)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), true
+}
+
+func importPathValue(spec *ast.ImportSpec) string {
+	val, _ := strconv.Unquote(spec.Path.Value)
+	return val
+}
+
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name == nil {
+		return ""
+	}
+	return spec.Name.Name
+}
+
+// lineStart returns the position of the start of the given line, or the end
+// of the file if the line is past the last line.
+func lineStart(tokFile *token.File, line int) token.Pos {
+	if line > tokFile.LineCount() {
+		return token.Pos(tokFile.Base() + tokFile.Size())
+	}
+	return tokFile.LineStart(line)
+}
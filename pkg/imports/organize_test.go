@@ -0,0 +1,211 @@
+package imports
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestOrganizer_Group(t *testing.T) {
+	o := NewOrganizer("github.com/mmihic/go-tools", "github.com/mmihic/other/")
+	for _, tt := range []struct {
+		importPath string
+		want       ImportGroup
+	}{
+		{"fmt", StdGroup},
+		{"net/http", StdGroup},
+		{"github.com/stretchr/testify/assert", ThirdPartyGroup},
+		{"gopkg.in/yaml.v2", ThirdPartyGroup},
+		{"github.com/mmihic/go-tools", LocalGroup},
+		{"github.com/mmihic/go-tools/pkg/path", LocalGroup},
+		{"github.com/mmihic/other/pkg", LocalGroup},
+		{"github.com/mmihic/go-tools-extra", ThirdPartyGroup},
+	} {
+		assert.Equal(t, tt.want, o.Group(path.NewPath(tt.importPath)), tt.importPath)
+	}
+}
+
+func TestOrganizer_Organize(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "regroups and sorts",
+			src: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/path"
+	"os"
+	"github.com/stretchr/testify/assert"
+
+	"fmt"
+	yaml "gopkg.in/yaml.v2"
+	"github.com/mmihic/go-tools/pkg/ident"
+)
+`,
+			want: `
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/stretchr/testify/assert"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/mmihic/go-tools/pkg/ident"
+	"github.com/mmihic/go-tools/pkg/path"
+)
+`,
+		},
+		{
+			name: "keeps comments with their imports",
+			src: `
+package main
+
+import (
+	// the local one
+	"github.com/mmihic/go-tools/pkg/path" // trailing
+
+	// floating comment
+
+	"os"
+	"github.com/stretchr/testify/assert" // assertions
+	// at the end
+)
+`,
+			want: `
+package main
+
+import (
+	// floating comment
+	"os"
+
+	"github.com/stretchr/testify/assert" // assertions
+
+	// the local one
+	"github.com/mmihic/go-tools/pkg/path" // trailing
+	// at the end
+)
+`,
+		},
+		{
+			name: "sorts by name for the same path",
+			src: `
+package main
+
+import (
+	b "fmt"
+	a "fmt"
+)
+`,
+			want: `
+package main
+
+import (
+	a "fmt"
+	b "fmt"
+)
+`,
+		},
+		{
+			name: "leaves cgo imports alone",
+			src: `
+package main
+
+import (
+	"os"
+
+	"C"
+
+	"fmt"
+)
+`,
+			want: `
+package main
+
+import (
+	"os"
+
+	"C"
+
+	"fmt"
+)
+`,
+		},
+		{
+			name: "organizes imports after a cgo preamble",
+			src: `
+package main
+
+/*
+#cgo CFLAGS: -DPNG_DEBUG=1
+#cgo linux LDFLAGS: -lpng
+#include <png.h>
+*/
+import "C"
+
+import (
+	"github.com/mmihic/go-tools/pkg/path"
+	"unsafe"
+	"fmt"
+)
+
+//export Add
+func Add(a, b C.int) C.int { return a + b }
+`,
+			want: `
+package main
+
+/*
+#cgo CFLAGS: -DPNG_DEBUG=1
+#cgo linux LDFLAGS: -lpng
+#include <png.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+//export Add
+func Add(a, b C.int) C.int { return a + b }
+`,
+		},
+		{
+			name: "leaves single imports alone",
+			src: `
+package main
+
+import "os"
+
+import "fmt"
+`,
+			want: `
+package main
+
+import "os"
+
+import "fmt"
+`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := NewOrganizer("github.com/mmihic/go-tools").Organize([]byte(tt.src))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Equal(t, strings.TrimSpace(tt.want), strings.TrimSpace(string(results)))
+		})
+	}
+}
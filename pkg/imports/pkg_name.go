@@ -0,0 +1,121 @@
+package imports
+
+import (
+	"go/build"
+	"path/filepath"
+	"sync"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// A PkgNameResolver resolves the name declared by the package at an import path.
+type PkgNameResolver interface {
+	// PkgName returns the name of the package at the given import path, or
+	// false if the package could not be found.
+	PkgName(importPath path.Path) (string, bool)
+}
+
+// PkgNameResolverFunc adapts a function to a PkgNameResolver.
+type PkgNameResolverFunc func(importPath path.Path) (string, bool)
+
+// PkgName returns the name of the package at the given import path.
+func (f PkgNameResolverFunc) PkgName(importPath path.Path) (string, bool) {
+	return f(importPath)
+}
+
+var cgoPath = path.NewPath("C")
+
+// PkgName returns the name of the package at the given import path as named
+// by a nil Namer, which assumes the name from the path.
+func PkgName(importPath path.Path) string {
+	return (*Namer)(nil).PkgName(importPath)
+}
+
+// SourcePkgNames resolves package names by reading the package clause of the
+// package's source. Packages within the local package root are found relative
+// to its directory; all others are found in the enclosing module's vendor
+// directory, GOROOT, or the module cache, without running the go tool. Results
+// are cached by import path, and the resolver is safe for concurrent use.
+type SourcePkgNames struct {
+	localPkgRoot path.Path
+	dir          string
+
+	modsOnce sync.Once
+	mods     *moduleDirs
+
+	mu    sync.Mutex
+	names map[string]*resolvedName
+}
+
+type resolvedName struct {
+	name string
+	ok   bool
+}
+
+// NewSourcePkgNames creates a new SourcePkgNames for the local package root
+// residing in the given directory.
+func NewSourcePkgNames(localPkgRoot path.Path, dir string) *SourcePkgNames {
+	return &SourcePkgNames{
+		localPkgRoot: localPkgRoot,
+		dir:          dir,
+		names:        map[string]*resolvedName{},
+	}
+}
+
+// PkgName returns the name of the package at the given import path.
+func (r *SourcePkgNames) PkgName(importPath path.Path) (string, bool) {
+	key := importPath.String()
+
+	r.mu.Lock()
+	resolved, ok := r.names[key]
+	r.mu.Unlock()
+
+	if !ok {
+		// NB(mmihic): Resolved without holding the lock, so that lookups of
+		// other packages aren't held up reading source. Concurrent lookups of the
+		// same package may both resolve it, but always to the same name.
+		resolved = r.resolve(importPath)
+
+		r.mu.Lock()
+		r.names[key] = resolved
+		r.mu.Unlock()
+	}
+
+	return resolved.name, resolved.ok
+}
+
+func (r *SourcePkgNames) resolve(importPath path.Path) *resolvedName {
+	if r.localPkgRoot.Contains(importPath) {
+		rel := importPath[len(r.localPkgRoot):]
+		return readPkgName(filepath.Join(append([]string{r.dir}, rel...)...))
+	}
+
+	r.modsOnce.Do(func() {
+		r.mods = newModuleDirs(r.dir)
+	})
+
+	for _, dir := range r.mods.Dirs(importPath.String()) {
+		if resolved := readPkgName(dir); resolved.ok {
+			return resolved
+		}
+	}
+
+	return &resolvedName{}
+}
+
+// readPkgName reads the name of the package in the given directory.
+func readPkgName(dir string) *resolvedName {
+	// NB(mmihic): ImportDir only reads the directory, unlike Import which may
+	// run the go tool to find the package, and with it update go.mod.
+	pkg, err := build.Default.ImportDir(dir, 0)
+	if err != nil {
+		// NB(mmihic): Directories with files from more than one package, such as
+		// tests or ignored generators, still tell us the name of the package.
+		if multiple, ok := err.(*build.MultiplePackageError); ok {
+			return &resolvedName{name: multiple.Packages[0], ok: true}
+		}
+		return &resolvedName{}
+	}
+
+	return &resolvedName{name: pkg.Name, ok: pkg.Name != ""}
+}
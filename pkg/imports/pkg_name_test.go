@@ -0,0 +1,152 @@
+package imports
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestSourcePkgNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgnames")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	modCache := filepath.Join(dir, "modcache")
+	t.Setenv("GOMODCACHE", modCache)
+
+	writeFiles(t, dir, map[string]string{
+		"local/go.mod": `module github.com/mmihic/local
+
+go 1.14
+
+require (
+	github.com/mmihic/Upper v1.2.0
+	github.com/mmihic/replaced v1.0.0
+	github.com/mmihic/vendored v1.0.0
+	github.com/mmihic/nested v1.0.0
+	github.com/mmihic/nested/inner v1.1.0
+)
+
+replace github.com/mmihic/replaced => ../replaced
+`,
+		"local/go-first/first.go":        "package first\n",
+		"local/go-first/first_x_test.go": "package first\n",
+		"local/second/v2/second.go":      "package second\n",
+		"local/third/third.go":           "package other\n",
+		"local/third/third_test.go":      "package other_test\n",
+		"local/fourth/gen.go":            "// +build ignore\n\npackage main\n",
+		"local/fourth/fourth.go":         "package fourth\n",
+		"local/empty/README.md":          "nothing to see here\n",
+
+		"local/vendor/github.com/mmihic/vendored/pkg/vendored.go": "package fromvendor\n",
+		"replaced/pkg/replaced.go":                                "package fromreplace\n",
+
+		"modcache/github.com/mmihic/!upper@v1.2.0/pkg/upper.go":         "package upper\n",
+		"modcache/github.com/mmihic/nested@v1.0.0/inner/outer.go":       "package outer\n",
+		"modcache/github.com/mmihic/nested/inner@v1.1.0/inner.go":       "package inner\n",
+		"modcache/github.com/mmihic/unrequired@v1.0.0/unrequired.go":    "package unrequired\n",
+		"modcache/github.com/mmihic/vendored@v1.0.0/pkg/notvendored.go": "package notvendored\n",
+	})
+
+	r := NewSourcePkgNames(path.NewPath("github.com/mmihic/local"), filepath.Join(dir, "local"))
+	for _, tt := range []struct {
+		importPath string
+		want       string
+		wantOK     bool
+	}{
+		{"github.com/mmihic/local/go-first", "first", true},
+		{"github.com/mmihic/local/second/v2", "second", true},
+		{"github.com/mmihic/local/third", "other", true},
+		{"github.com/mmihic/local/fourth", "fourth", true},
+		{"github.com/mmihic/local/empty", "", false},
+		{"github.com/mmihic/local/missing", "", false},
+		{"github.com/mmihic/Upper/pkg", "upper", true},
+		{"github.com/mmihic/nested/inner", "inner", true},
+		{"github.com/mmihic/replaced/pkg", "fromreplace", true},
+		{"github.com/mmihic/vendored/pkg", "fromvendor", true},
+		{"github.com/mmihic/unrequired", "", false},
+		{"strings", "strings", true},
+	} {
+		name, ok := r.PkgName(path.NewPath(tt.importPath))
+		assert.Equal(t, tt.wantOK, ok, tt.importPath)
+		assert.Equal(t, tt.want, name, tt.importPath)
+	}
+
+	// Nothing outside of the source is touched
+	data, err := ioutil.ReadFile(filepath.Join(dir, "local", "go.mod"))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "go 1.14\n")
+	_, err = os.Stat(filepath.Join(dir, "local", "go.sum"))
+	assert.True(t, os.IsNotExist(err), "go.sum created")
+}
+
+func TestSourcePkgNames_Concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pkgnames")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	writeFiles(t, dir, map[string]string{
+		"go.mod":         "module github.com/mmihic/local\n",
+		"first/first.go": "package first\n",
+	})
+
+	r := NewSourcePkgNames(path.NewPath("github.com/mmihic/local"), dir)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, ok := r.PkgName(path.NewPath("github.com/mmihic/local/first"))
+			assert.True(t, ok)
+			assert.Equal(t, "first", name)
+
+			_, ok = r.PkgName(path.NewPath("github.com/mmihic/missing"))
+			assert.False(t, ok)
+		}()
+	}
+	wg.Wait()
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for fname, contents := range files {
+		fname = filepath.Join(dir, filepath.FromSlash(fname))
+		require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0755))
+		require.NoError(t, ioutil.WriteFile(fname, []byte(contents), 0644))
+	}
+}
+
+func TestNamer(t *testing.T) {
+	namer := NewNamer(PkgNameResolverFunc(func(importPath path.Path) (string, bool) {
+		if importPath.String() == "github.com/mmihic/go-first" {
+			return "notfirst", true
+		}
+		return "", false
+	}), nil)
+
+	assert.Equal(t, "notfirst", namer.PkgName(path.NewPath("github.com/mmihic/go-first")))
+	assert.Equal(t, "second", namer.PkgName(path.NewPath("github.com/mmihic/go-second")))
+	assert.Equal(t, "C", namer.PkgName(path.NewPath("C")))
+
+	assert.False(t, namer.RequiresAlias(path.NewPath("github.com/mmihic/go-first"), "notfirst"))
+	assert.True(t, namer.RequiresAlias(path.NewPath("github.com/mmihic/go-first"), "first"))
+	assert.True(t, namer.RequiresAlias(path.NewPath("github.com/mmihic/go-second"), "second"))
+	assert.False(t, namer.RequiresAlias(path.NewPath("github.com/mmihic/third"), "third"))
+	assert.True(t, namer.RequiresAlias(path.NewPath("gopkg.in/yaml.v2"), "yaml"))
+
+	// Without a resolver, names are assumed from the path
+	assert.Equal(t, "first", PkgName(path.NewPath("github.com/mmihic/go-first")))
+	assert.True(t, (*Namer)(nil).RequiresAlias(path.NewPath("github.com/mmihic/go-first"), "first"))
+	assert.Equal(t, DefaultAliasPolicy, (*Namer)(nil).AliasPolicy())
+}
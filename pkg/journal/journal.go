@@ -0,0 +1,213 @@
+// Package journal records the changes made by a migration, so that it can
+// later be undone.
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/filter"
+	"github.com/mmihic/go-tools/pkg/pkgs"
+)
+
+// DefaultFilename is the name of the journal file written by default.
+const DefaultFilename = ".pkgalign-journal.yaml"
+
+// A Journal records the moves applied to a tree, and the files rewritten as a
+// result.
+type Journal struct {
+	// LocalPkgRoot is the package root of the tree.
+	LocalPkgRoot string `yaml:"local_pkg_root"`
+
+	// Dir is the directory from which the moves were applied.
+	Dir string `yaml:"dir"`
+
+	// Local are the import path prefixes grouped as local imports.
+	Local []string `yaml:"local,omitempty"`
+
+	// Filter selects the directories and files that were rewritten.
+	Filter filter.Filter `yaml:",inline"`
+
+	// Moves are the moves applied, relative to the local package root.
+	Moves pkgs.Moves `yaml:"packages"`
+
+	// Files are the files rewritten, sorted by path.
+	Files []*File `yaml:"files"`
+
+	// Complete is set once all of the files have been written. The journal is
+	// written before any of them are, so a run that dies part way through
+	// leaves an incomplete journal, in which some of the files may still have
+	// their original contents.
+	Complete bool `yaml:"complete"`
+}
+
+// A File is a file rewritten by the migration.
+type File struct {
+	Path string `yaml:"path"`
+
+	// The hashes of the contents of the file before and after the rewrite. The
+	// before hash is empty for files created by the rewrite, and the after hash
+	// for files whose new contents aren't known until they have been written.
+	Before string `yaml:"before,omitempty"`
+	After  string `yaml:"after,omitempty"`
+
+	// Original is the contents of the file before the rewrite, from which it
+	// is restored when the migration is undone.
+	Original string `yaml:"original,omitempty"`
+}
+
+// Hash returns the hash under which contents are recorded in the journal.
+func Hash(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+// Record records the given changes in the journal. Changes to files already
+// recorded replace the earlier changes.
+func (j *Journal) Record(changes []*astio.Change) {
+	for _, change := range changes {
+		f := j.file(change.Filename)
+		f.After = Hash(change.After)
+
+		f.Before, f.Original = "", ""
+		if change.Before != nil {
+			f.Before = Hash(change.Before)
+			f.Original = string(change.Before)
+		}
+	}
+}
+
+// RecordPending records that the named file, with the given contents, is about
+// to be rewritten by something other than the migration itself, such as a
+// generator, so that its new contents aren't yet known. The file is forgotten
+// on completion unless its changes have been recorded in the meantime.
+func (j *Journal) RecordPending(fname string, before []byte) {
+	f := j.file(fname)
+	f.Before, f.After = Hash(before), ""
+	f.Original = string(before)
+}
+
+// MarkComplete marks the journal as complete, once all of the files it
+// records have been written, forgetting any files still pending.
+func (j *Journal) MarkComplete() {
+	files := j.Files[:0]
+	for _, f := range j.Files {
+		if f.After != "" {
+			files = append(files, f)
+		}
+	}
+
+	j.Files = files
+	j.Complete = true
+}
+
+// file returns the record of the named file, adding one if needed.
+func (j *Journal) file(fname string) *File {
+	for _, f := range j.Files {
+		if f.Path == fname {
+			return f
+		}
+	}
+
+	f := &File{Path: fname}
+	j.Files = append(j.Files, f)
+
+	files := j.Files
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].Path < files[b].Path
+	})
+	return f
+}
+
+// Verify checks that none of the files recorded in the journal have been
+// modified since they were rewritten, and that the journal holds the original
+// contents of each of them. If the journal is incomplete, files may also still
+// have their original contents, or not yet exist if created by the run, and
+// pending files may have any contents.
+func (j *Journal) Verify() error {
+	var modified []string
+	for _, f := range j.Files {
+		if f.Before != "" && Hash([]byte(f.Original)) != f.Before {
+			return fmt.Errorf("journal does not hold the original contents of %s", f.Path)
+		}
+
+		contents, err := ioutil.ReadFile(f.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if !j.Complete {
+			if f.After == "" || (err != nil && f.Before == "") || (err == nil && Hash(contents) == f.Before) {
+				continue
+			}
+		}
+
+		if err != nil || Hash(contents) != f.After {
+			modified = append(modified, f.Path)
+		}
+	}
+
+	if len(modified) != 0 {
+		return fmt.Errorf("files modified since the migration: %s", strings.Join(modified, ", "))
+	}
+
+	return nil
+}
+
+// Restore stages the original contents of the files recorded in the journal
+// to be written by the transaction, returning the files created by the
+// migration, which have no original contents and should be removed. Files
+// created by an incomplete migration may not exist.
+func (j *Journal) Restore(tx *astio.Transaction) ([]string, error) {
+	var created []string
+	for _, f := range j.Files {
+		if f.Before == "" {
+			created = append(created, f.Path)
+			continue
+		}
+
+		if err := tx.WriteBytes(f.Path, []byte(f.Original)); err != nil {
+			return nil, fmt.Errorf("unable to restore %s: %v", f.Path, err)
+		}
+	}
+
+	return created, nil
+}
+
+// Read reads the journal from the named file.
+func Read(fname string) (*Journal, error) {
+	contents, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	var j Journal
+	if err := yaml.Unmarshal(contents, &j); err != nil {
+		return nil, fmt.Errorf("unable to parse journal %s: %v", fname, err)
+	}
+
+	return &j, nil
+}
+
+// Write writes the journal to the named file.
+func (j *Journal) Write(fname string) error {
+	contents, err := yaml.Marshal(j)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fname, contents, 0644)
+}
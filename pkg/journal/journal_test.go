@@ -0,0 +1,168 @@
+package journal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/pkgs"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	first, second := filepath.Join(dir, "first.go"), filepath.Join(dir, "second.go")
+	for _, fname := range []string{first, second} {
+		require.NoError(t, ioutil.WriteFile(fname, []byte("package other\n"), 0644))
+	}
+
+	moves, err := pkgs.ParseMoves([]string{"pkg/first:pkg/other"})
+	require.NoError(t, err)
+
+	j := &Journal{
+		LocalPkgRoot: "github.com/mmihic/go-tools",
+		Dir:          ".",
+		Moves:        moves,
+	}
+	j.Record([]*astio.Change{
+		{Filename: second, After: []byte("package other\n")},
+		{Filename: first, Before: []byte("package first\n"), After: []byte("package other\n")},
+	})
+
+	require.Len(t, j.Files, 2)
+	assert.Equal(t, first, j.Files[0].Path)
+	assert.Equal(t, Hash([]byte("package first\n")), j.Files[0].Before)
+	assert.Equal(t, Hash([]byte("package other\n")), j.Files[0].After)
+	assert.Equal(t, "package first\n", j.Files[0].Original)
+	assert.Equal(t, "", j.Files[1].Before)
+
+	// Round trip through a file
+	fname := filepath.Join(dir, DefaultFilename)
+	require.NoError(t, j.Write(fname))
+	read, err := Read(fname)
+	require.NoError(t, err)
+	assert.Equal(t, j, read)
+
+	// Detect modifications
+	require.NoError(t, read.Verify())
+	require.NoError(t, ioutil.WriteFile(second, []byte("package modified\n"), 0644))
+	err = read.Verify()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), second)
+		assert.NotContains(t, err.Error(), first)
+	}
+
+	require.NoError(t, os.Remove(first))
+	assert.Error(t, read.Verify())
+}
+
+func TestJournal_Restore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	// NB(mmihic): Originals are restored byte for byte, including line endings,
+	// byte order marks and anything else the rewrite normalized.
+	original := "\ufeffpackage first\r\n\r\nimport (\r\n\t\"b\"\r\n\t\"a\"\r\n)\r\n"
+	first, created := filepath.Join(dir, "first.go"), filepath.Join(dir, "created.go")
+	require.NoError(t, ioutil.WriteFile(first, []byte("package other\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(created, []byte("package other\n"), 0644))
+
+	j := &Journal{}
+	j.Record([]*astio.Change{
+		{Filename: first, Before: []byte(original), After: []byte("package other\n")},
+		{Filename: created, After: []byte("package other\n")},
+	})
+
+	fname := filepath.Join(dir, DefaultFilename)
+	require.NoError(t, j.Write(fname))
+	read, err := Read(fname)
+	require.NoError(t, err)
+	require.NoError(t, read.Verify())
+
+	tx := astio.NewTransaction()
+	restoredCreated, err := read.Restore(tx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{created}, restoredCreated)
+	require.NoError(t, tx.Commit())
+
+	contents, err := ioutil.ReadFile(first)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(contents))
+
+	// A journal whose originals don't match their hashes can't be trusted
+	require.Equal(t, first, read.Files[1].Path)
+	read.Files[1].Original = "package first\n"
+	err = read.Verify()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "original contents of "+first)
+	}
+}
+
+func TestJournal_Incomplete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	written, unwritten := filepath.Join(dir, "written.go"), filepath.Join(dir, "unwritten.go")
+	created, generated := filepath.Join(dir, "created.go"), filepath.Join(dir, "generated.go")
+	require.NoError(t, ioutil.WriteFile(written, []byte("package other\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(unwritten, []byte("package first\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(generated, []byte("package partial\n"), 0644))
+
+	j := &Journal{}
+	j.Record([]*astio.Change{
+		{Filename: written, Before: []byte("package first\n"), After: []byte("package other\n")},
+		{Filename: unwritten, Before: []byte("package first\n"), After: []byte("package other\n")},
+		{Filename: created, After: []byte("package other\n")},
+	})
+	j.RecordPending(generated, []byte("package first\n"))
+	require.Len(t, j.Files, 4)
+
+	// The run died part way through writing, so files may still be unwritten
+	// and pending files may have any contents
+	require.NoError(t, j.Verify())
+
+	tx := astio.NewTransaction()
+	restoredCreated, err := j.Restore(tx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{created}, restoredCreated)
+	assert.ElementsMatch(t, []string{written, generated}, tx.Files())
+	tx.Rollback()
+
+	// Once complete, pending files that weren't changed are forgotten, and
+	// every file must have been written
+	j.Record([]*astio.Change{
+		{Filename: generated, Before: []byte("package first\n"), After: []byte("package partial\n")},
+	})
+	j.MarkComplete()
+	require.Len(t, j.Files, 4)
+	assert.Equal(t, Hash([]byte("package partial\n")), j.Files[1].After)
+	assert.Equal(t, "package first\n", j.Files[1].Original)
+
+	err = j.Verify()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), unwritten)
+		assert.Contains(t, err.Error(), created)
+		assert.NotContains(t, err.Error(), written)
+	}
+
+	pending := &Journal{}
+	pending.RecordPending(generated, []byte("package first\n"))
+	pending.MarkComplete()
+	assert.Empty(t, pending.Files)
+	assert.True(t, pending.Complete)
+}
@@ -0,0 +1,244 @@
+// Package logging provides a leveled logger writing either plain text or JSON
+// lines, with each entry carrying a message and a list of key value pairs.
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// A Level is the amount of detail logged.
+type Level int
+
+const (
+	// Quiet logs only warnings.
+	Quiet Level = iota
+
+	// Normal also logs progress and summaries.
+	Normal
+
+	// Verbose also logs each file written.
+	Verbose
+
+	// Debug also logs each file processed or skipped.
+	Debug
+)
+
+var levelNames = []string{"quiet", "normal", "verbose", "debug"}
+
+// ParseLevel parses the name of a level.
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if name == s {
+			return Level(i), nil
+		}
+	}
+
+	return Quiet, fmt.Errorf("invalid log level %s, must be one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if l < Quiet || int(l) >= len(levelNames) {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+
+	return levelNames[l]
+}
+
+// Formats in which entries are written.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// A Logger writes entries at or below its level. It is safe for concurrent use.
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+	json  bool
+
+	// now returns the time of an entry, and is replaced in tests
+	now func() time.Time
+}
+
+// New creates a new Logger writing entries at or below the given level to w,
+// in the given format.
+func New(w io.Writer, level Level, format string) (*Logger, error) {
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("invalid log format %s, must be %s or %s", format, FormatText, FormatJSON)
+	}
+
+	return &Logger{
+		w:     w,
+		level: level,
+		json:  format == FormatJSON,
+		now:   time.Now,
+	}, nil
+}
+
+// Enabled returns true if entries at the given level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level <= l.level
+}
+
+// entryNames are the names of the levels as they appear in entries.
+var entryNames = []string{"warn", "info", "verbose", "debug"}
+
+// Warn logs a warning, which is written at every level.
+func (l *Logger) Warn(msg string, kvs ...interface{}) {
+	l.log(Quiet, msg, kvs)
+}
+
+// Info logs progress or a summary.
+func (l *Logger) Info(msg string, kvs ...interface{}) {
+	l.log(Normal, msg, kvs)
+}
+
+// Verbose logs detail about the changes being made.
+func (l *Logger) Verbose(msg string, kvs ...interface{}) {
+	l.log(Verbose, msg, kvs)
+}
+
+// Debug logs detail about everything being examined.
+func (l *Logger) Debug(msg string, kvs ...interface{}) {
+	l.log(Debug, msg, kvs)
+}
+
+// Table logs a table at the given level. As text, the message is followed by
+// the rows aligned in columns under a header; as JSON, each row is an entry
+// with the message, keyed by the column names.
+func (l *Logger) Table(level Level, msg string, columns []string, rows [][]string) {
+	if !l.Enabled(level) || len(rows) == 0 {
+		return
+	}
+
+	if l.json {
+		for _, row := range rows {
+			kvs := make([]interface{}, 0, 2*len(columns))
+			for i, column := range columns {
+				kvs = append(kvs, column, row[i])
+			}
+			l.log(level, msg, kvs)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	writeText(&buf, entryNames[level], msg+":", nil)
+	buf.WriteByte('\n')
+
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "    %s\n", strings.ToUpper(strings.Join(columns, "\t")))
+	for _, row := range rows {
+		fmt.Fprintf(tw, "    %s\n", strings.Join(row, "\t"))
+	}
+	_ = tw.Flush()
+
+	l.write(buf.Bytes())
+}
+
+func (l *Logger) log(level Level, msg string, kvs []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	name := entryNames[level]
+
+	var buf bytes.Buffer
+	if l.json {
+		writeJSON(&buf, l.now(), name, msg, kvs)
+	} else {
+		writeText(&buf, name, msg, kvs)
+	}
+	buf.WriteByte('\n')
+
+	l.write(buf.Bytes())
+}
+
+func (l *Logger) write(entry []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, _ = l.w.Write(entry)
+}
+
+// writeText writes an entry as the message followed by key=value pairs.
+func writeText(buf *bytes.Buffer, name, msg string, kvs []interface{}) {
+	if name == "warn" {
+		buf.WriteString("warning: ")
+	}
+	buf.WriteString(msg)
+
+	for i := 0; i < len(kvs); i += 2 {
+		key, value := pair(kvs, i)
+		s := fmt.Sprint(value)
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = fmt.Sprintf("%q", s)
+		}
+
+		fmt.Fprintf(buf, " %s=%s", key, s)
+	}
+}
+
+// writeJSON writes an entry as a single JSON object, with the keys in the order
+// given.
+func writeJSON(buf *bytes.Buffer, now time.Time, name, msg string, kvs []interface{}) {
+	// NB(mmihic): Encoding a map would sort the keys, putting the time, level and
+	// message in among the rest.
+	fields := []interface{}{"time", now.UTC().Format(time.RFC3339Nano), "level", name, "msg", msg}
+	fields = append(fields, kvs...)
+
+	buf.WriteByte('{')
+	for i := 0; i < len(fields); i += 2 {
+		if i != 0 {
+			buf.WriteByte(',')
+		}
+
+		key, value := pair(fields, i)
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+
+		encodedKey, _ := marshal(key)
+		encodedValue, err := marshal(value)
+		if err != nil {
+			encodedValue, _ = marshal(fmt.Sprint(value))
+		}
+
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		buf.Write(encodedValue)
+	}
+	buf.WriteByte('}')
+}
+
+// marshal encodes a value as JSON, without escaping the characters that are
+// special to HTML, such as the > of an arrow.
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// pair returns the key and value starting at index i, flagging a key without
+// a value rather than dropping it.
+func pair(kvs []interface{}, i int) (string, interface{}) {
+	if i+1 >= len(kvs) {
+		return "!BADKEY", kvs[i]
+	}
+
+	return fmt.Sprint(kvs[i]), kvs[i+1]
+}
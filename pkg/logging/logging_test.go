@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	for _, level := range []Level{Quiet, Normal, Verbose, Debug} {
+		parsed, err := ParseLevel(level.String())
+		require.NoError(t, err)
+		assert.Equal(t, level, parsed)
+	}
+
+	_, err := ParseLevel("loud")
+	assert.EqualError(t, err, "invalid log level loud, must be one of quiet, normal, verbose, debug")
+}
+
+func TestLogger(t *testing.T) {
+	log := func(l *Logger) {
+		l.Warn("skipping generated file", "file", "pkg/a/a.pb.go")
+		l.Info("progress", "dirs_processed", 3, "errors", 0)
+		l.Verbose("rewriting", "file", "pkg/a/a.go")
+		l.Debug("skipping", "path", "testdata", "reason", "ignored by the go tool")
+	}
+
+	tests := []struct {
+		name   string
+		level  Level
+		format string
+		want   string
+	}{
+		{"quiet", Quiet, FormatText, `warning: skipping generated file file=pkg/a/a.pb.go
+`},
+		{"normal", Normal, FormatText, `warning: skipping generated file file=pkg/a/a.pb.go
+progress dirs_processed=3 errors=0
+`},
+		{"debug", Debug, FormatText, `warning: skipping generated file file=pkg/a/a.pb.go
+progress dirs_processed=3 errors=0
+rewriting file=pkg/a/a.go
+skipping path=testdata reason="ignored by the go tool"
+`},
+		{"json", Verbose, FormatJSON, `{"time":"2020-07-01T12:00:00Z","level":"warn","msg":"skipping generated file","file":"pkg/a/a.pb.go"}
+{"time":"2020-07-01T12:00:00Z","level":"info","msg":"progress","dirs_processed":3,"errors":0}
+{"time":"2020-07-01T12:00:00Z","level":"verbose","msg":"rewriting","file":"pkg/a/a.go"}
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l, err := New(&buf, tt.level, tt.format)
+			require.NoError(t, err)
+			l.now = func() time.Time { return time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC) }
+
+			log(l)
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestLogger_Values(t *testing.T) {
+	var text, js bytes.Buffer
+	textLog, err := New(&text, Normal, FormatText)
+	require.NoError(t, err)
+	jsonLog, err := New(&js, Normal, FormatJSON)
+	require.NoError(t, err)
+	jsonLog.now = func() time.Time { return time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC) }
+
+	for _, l := range []*Logger{textLog, jsonLog} {
+		l.Info("failed", "error", errors.New("no such file <a -> b>"), "empty", "", "dangling")
+	}
+
+	assert.Equal(t, `failed error="no such file <a -> b>" empty="" !BADKEY=dangling
+`, text.String())
+	assert.Equal(t, `{"time":"2020-07-01T12:00:00Z","level":"info","msg":"failed","error":"no such file <a -> b>","empty":"","!BADKEY":"dangling"}
+`, js.String())
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, Normal, "xml")
+	assert.EqualError(t, err, "invalid log format xml, must be text or json")
+}
+
+func TestLogger_Table(t *testing.T) {
+	columns := []string{"file", "line", "error"}
+	rows := [][]string{
+		{"pkg/a/a.go", "12", "syntax error"},
+		{"pkg/second/second_amd64.s", "3", "unable to refer to pkg/go-second from assembly"},
+	}
+
+	var text, js, quiet bytes.Buffer
+	textLog, err := New(&text, Normal, FormatText)
+	require.NoError(t, err)
+	jsonLog, err := New(&js, Normal, FormatJSON)
+	require.NoError(t, err)
+	jsonLog.now = func() time.Time { return time.Date(2020, 7, 1, 12, 0, 0, 0, time.UTC) }
+	quietLog, err := New(&quiet, Quiet, FormatText)
+	require.NoError(t, err)
+
+	for _, l := range []*Logger{textLog, jsonLog, quietLog} {
+		l.Table(Quiet, "errors", columns, rows)
+		l.Table(Normal, "summary", columns, rows[:1])
+		l.Table(Quiet, "empty", columns, nil)
+	}
+
+	assert.Equal(t, `warning: errors:
+    FILE                       LINE  ERROR
+    pkg/a/a.go                 12    syntax error
+    pkg/second/second_amd64.s  3     unable to refer to pkg/go-second from assembly
+summary:
+    FILE        LINE  ERROR
+    pkg/a/a.go  12    syntax error
+`, text.String())
+
+	assert.Equal(t, `{"time":"2020-07-01T12:00:00Z","level":"warn","msg":"errors","file":"pkg/a/a.go","line":"12","error":"syntax error"}
+{"time":"2020-07-01T12:00:00Z","level":"warn","msg":"errors","file":"pkg/second/second_amd64.s","line":"3","error":"unable to refer to pkg/go-second from assembly"}
+{"time":"2020-07-01T12:00:00Z","level":"info","msg":"summary","file":"pkg/a/a.go","line":"12","error":"syntax error"}
+`, js.String())
+
+	assert.Equal(t, `warning: errors:
+    FILE                       LINE  ERROR
+    pkg/a/a.go                 12    syntax error
+    pkg/second/second_amd64.s  3     unable to refer to pkg/go-second from assembly
+`, quiet.String())
+}
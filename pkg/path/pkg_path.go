@@ -8,8 +8,13 @@ import (
 // A Path is a path.
 type Path []string
 
-// NewPath creates a new path.
+// NewPath creates a new path. The empty string is the empty path, rather
+// than a path with a single empty element.
 func NewPath(s string) Path {
+	if s == "" {
+		return Path{}
+	}
+
 	return strings.Split(s, "/")
 }
 
@@ -55,8 +60,15 @@ func (p Path) Contains(other Path) bool {
 	return true
 }
 
-// PkgName returns the name of the package.
+// PkgName returns the last element of the path, which by convention is the
+// name of the package. The package may declare a different name; use
+// imports.PkgName to resolve the name actually declared by the package. The
+// empty path has no package name, and returns "".
 func (p Path) PkgName() string {
+	if len(p) == 0 {
+		return ""
+	}
+
 	return p[len(p)-1]
 }
 
@@ -22,10 +22,15 @@ func TestPath_Equal(t *testing.T) {
 
 func TestPath_Append(t *testing.T) {
 	assert.Equal(t,
-		NewPath("github.com/mmihic").Append(NewPath("tools/pkgalign")),
+		NewPath("github.com/mmihic").Append(NewPath("go-tools/tools/pkgalign")),
 		NewPath("github.com/mmihic/go-tools/tools/pkgalign"))
 	assert.Equal(t,
 		NewPath("").Append(NewPath("github.com/mmihic/go-tools/tools/pkgalign")),
 		NewPath("github.com/mmihic/go-tools/tools/pkgalign"))
 }
 
+
+func TestPath_PkgName(t *testing.T) {
+	assert.Equal(t, "pkgalign", NewPath("github.com/mmihic/go-tools/tools/pkgalign").PkgName())
+	assert.Equal(t, "", NewPath("").PkgName())
+}
@@ -9,93 +9,200 @@ import (
 
 	"golang.org/x/tools/go/ast/astutil"
 
+	"github.com/mmihic/go-tools/pkg/buildtags"
 	"github.com/mmihic/go-tools/pkg/imports"
 	"github.com/mmihic/go-tools/pkg/path"
 	"github.com/mmihic/go-tools/pkg/scope"
 )
 
 // Apply updates all of the imports in the given file to reflect the new package
-// locations. Returns the set of modified files.
+// locations, naming imports from their paths and with the DefaultAliasPolicy.
+// Returns true if the file was changed.
 func (moves Moves) Apply(fset *token.FileSet, pkgPath path.Path, f *ast.File) (bool, error) {
-	changed := false
+	return moves.Applier(nil).Apply(fset, pkgPath, f)
+}
+
+// ApplyChanges is like Apply, but returns a description of the changes made
+// to the file.
+func (moves Moves) ApplyChanges(fset *token.FileSet, pkgPath path.Path, f *ast.File) (*FileChanges, error) {
+	return moves.Applier(nil).ApplyChanges(fset, pkgPath, f)
+}
+
+// An Applier applies moves to files, naming the imports it rewrites with a
+// Namer. It is safe for concurrent use if the Namer is.
+type Applier struct {
+	moves Moves
+	namer *imports.Namer
+}
+
+// Applier returns an Applier for the moves, naming imports with the given Namer.
+// The Namer's resolver should resolve the names of packages as they will be once
+// moved, as returned by PkgNames.
+func (moves Moves) Applier(namer *imports.Namer) *Applier {
+	return &Applier{moves: moves, namer: namer}
+}
+
+// Apply updates all of the imports in the given file to reflect the new package
+// locations. Returns true if the file was changed.
+func (a *Applier) Apply(fset *token.FileSet, pkgPath path.Path, f *ast.File) (bool, error) {
+	changes, err := a.ApplyChanges(fset, pkgPath, f)
+	if err != nil {
+		return false, err
+	}
+
+	return changes.Changed(), nil
+}
+
+// ApplyChanges is like Apply, but returns a description of the changes made
+// to the file.
+func (a *Applier) ApplyChanges(fset *token.FileSet, pkgPath path.Path, f *ast.File) (*FileChanges, error) {
+	changes := &FileChanges{}
 
 	// NB(mmihic): The order here is important - we first need to change all of the imports, so that
 	// when we rewrite our package we can identity and remove self-imports
-	if moves.updateImports(fset, f) {
-		changed = true
+	if err := a.updateImports(fset, f, changes); err != nil {
+		return nil, err
 	}
 
-	if pkgPathMatch := moves.ExactMatch(pkgPath); pkgPathMatch != nil {
-		if pkgPathMatch.rewritePackage(fset, f) {
-			changed = true
-		}
+	generator := isGenerator(fset, f)
+	if pkgPathMatch := a.moves.ExactMatch(pkgPath); pkgPathMatch != nil && !generator {
+		a.rewritePackage(fset, f, pkgPathMatch, changes)
+	}
+
+	// NB(mmihic): Imports of packages moving into the file's package become
+	// self-imports, whether or not the file's package is itself moving. External
+	// test packages import the package under test like any other package.
+	if generator || strings.HasSuffix(f.Name.Name, "_test") {
+		return changes, nil
+	}
+
+	newPkgPath := pkgPath
+	if pkgPathMatch := a.moves.BestMatch(pkgPath); pkgPathMatch != nil {
+		newPkgPath, _ = pkgPathMatch.Rewrite(pkgPath)
 	}
 
-	return changed, nil
+	if a.removeSelfImport(fset, f, newPkgPath) {
+		changes.SelfImportsRemoved = append(changes.SelfImportsRemoved, newPkgPath.String())
+	}
+
+	return changes, nil
 }
 
 // updateImports updates the imports in the given file to match the set of moves.
-func (moves Moves) updateImports(fset *token.FileSet, f *ast.File) bool {
+func (a *Applier) updateImports(fset *token.FileSet, f *ast.File, changes *FileChanges) error {
+	// NB(mmihic): Resolve the references to each import up front, since rewriting
+	// an import changes the name under which it is declared.
+	idx := scope.NewIndexNamed(f, a.namer)
+
 	// Find the best match for each import, and then use this to rewrite all of the
 	// references to that import.
-	changed := false
 	for _, imp := range f.Imports {
+		if imports.IsCgo(imp) {
+			continue
+		}
+
 		importPath := imports.Path(imp)
-		importMatch := moves.BestMatch(importPath)
+		importMatch := a.moves.BestMatch(importPath)
 		if importMatch == nil {
 			continue
 		}
 
-		oldName := imports.Name(imp)
+		oldName := a.namer.Name(imp)
 		rewrittenPath, _ := importMatch.Rewrite(importPath)
-		imp.Path.Value = strconv.Quote(rewrittenPath.String())
 
-		if oldName == "_" {
+		var existingAlias string
+		if imp.Name != nil {
+			existingAlias = imp.Name.Name
+		}
+
+		change := &ImportChange{
+			OldPath:  importPath.String(),
+			NewPath:  rewrittenPath.String(),
+			OldAlias: existingAlias,
+			NewAlias: existingAlias,
+			Spec:     imp,
+		}
+		changes.Imports = append(changes.Imports, change)
+
+		// NB(mmihic): Blank and dot imports aren't referenced by name, so only the
+		// path needs to change.
+		if oldName == "_" || oldName == "." {
+			imp.Path.Value = strconv.Quote(rewrittenPath.String())
 			continue
 		}
 
-		newName := imports.DisambiguateImportName(f, rewrittenPath)
-		if newName == rewrittenPath.PkgName() {
+		// NB(mmihic): The new path is only set once the name is chosen, so that
+		// the import is still declared under its old name while checking for conflicts.
+		refs := idx.References(imp)
+		newName, err := a.namer.DisambiguateImportNameFunc(rewrittenPath, existingAlias, func(name string) bool {
+			return scope.HasConflictNamed(f, a.namer, refs, name, isImportOf(imp, rewrittenPath))
+		})
+		if err != nil {
+			return &RewriteError{
+				Filename: fset.File(f.Pos()).Name(),
+				Pos:      fset.Position(imp.Pos()),
+				Move:     importMatch,
+				Cause:    err,
+			}
+		}
+
+		imp.Path.Value = strconv.Quote(rewrittenPath.String())
+
+		if !a.namer.RequiresAlias(rewrittenPath, newName) {
 			// Can just rely on the default package name
 			imp.Name = nil
+			change.NewAlias = ""
 		} else {
 			// we need to use an alias to disambiguate
 			imp.Name = &ast.Ident{
 				Name: newName,
 			}
+			change.NewAlias = newName
 		}
 
-		rewriteImportPrefix(f, oldName, newName)
-		changed = true
+		for _, ref := range refs {
+			ref.Name = newName
+		}
 	}
 
-	return changed
+	return nil
 }
 
-// rewriteImportPrefix changes the alias used for an import from one name to another.
-func rewriteImportPrefix(f *ast.File, oldName, newName string) {
-	scope.Inspect(f, func(n ast.Node, s *scope.Scope) bool {
-		if s.HasDecl(oldName) {
-			return false
-		}
+// isImportOf returns a function that checks whether a declaration is the given
+// import, or another import of the path to which it is being rewritten. Imports
+// of the same path never conflict with each other.
+func isImportOf(imp *ast.ImportSpec, importPath path.Path) func(n ast.Node) bool {
+	return func(n ast.Node) bool {
+		other, ok := n.(*ast.ImportSpec)
+		return ok && (other == imp || imports.Path(other).Equal(importPath))
+	}
+}
 
-		if sel, ok := n.(*ast.SelectorExpr); ok {
-			if ident, ok := sel.X.(*ast.Ident); ok {
-				if ident.Name == oldName {
-					ident.Name = newName
-				}
-			}
-		}
-		return true
-	})
+// isGenerator returns true if the file is a main package excluded from the
+// build with the ignore tag. These live alongside the package for use with go
+// run or go generate, and aren't part of the package being moved.
+func isGenerator(fset *token.FileSet, f *ast.File) bool {
+	return f.Name.Name == "main" && buildtags.Parse(fset.File(f.Pos()).Name(), f).Ignored()
 }
 
 // rewritePackage changes the package to which the given file belongs.
-func (mv *Move) rewritePackage(fset *token.FileSet, f *ast.File) bool {
+func (a *Applier) rewritePackage(fset *token.FileSet, f *ast.File, mv *Move, changes *FileChanges) {
 	// Change package decl
 	oldName := f.Name.Name
-	newName := mv.To.PkgName()
+	newName := mv.PkgName()
+
+	// NB(mmihic): External test packages keep their _test suffix, and import
+	// the package under test like any other package.
+	if strings.HasSuffix(oldName, "_test") {
+		newName += "_test"
+	}
+
+	if oldName == newName {
+		return
+	}
+
 	f.Name.Name = newName
+	changes.Package = &PackageChange{OldName: oldName, NewName: newName}
 
 	// Rewrite the package comments, if any
 	for _, cg := range f.Comments {
@@ -115,67 +222,53 @@ func (mv *Move) rewritePackage(fset *token.FileSet, f *ast.File) bool {
 			}
 		}
 	}
-
-	removeSelfImport(fset, f, mv.To)
-	return true
 }
 
-// removeSelfImport removes an import statement that now refers to the package
-// in which the file resides, also removing any name qualifier on declarations
-// that previously used the imported package.
-func removeSelfImport(fset *token.FileSet, f *ast.File, pkgPath path.Path) {
-	// Check to see if we import our new path - if so strip that import.
+// removeSelfImport removes the imports that refer to the package in which the
+// file resides, also removing the qualifier from any references to the
+// imported package. Returns true if any were removed.
+func (a *Applier) removeSelfImport(fset *token.FileSet, f *ast.File, pkgPath path.Path) bool {
+	var selfImports []*ast.ImportSpec
 	for _, imp := range f.Imports {
-		importPath := imports.Path(imp)
-		if !pkgPath.Equal(importPath) {
-			continue
+		if pkgPath.Equal(imports.Path(imp)) {
+			selfImports = append(selfImports, imp)
 		}
+	}
 
-		astutil.DeleteImport(fset, f, importPath.String())
-		removeImportPrefix(f, imports.Name(imp))
+	if len(selfImports) == 0 {
+		return false
 	}
-}
 
-func removeImportPrefix(f *ast.File, prefix string) {
-	scope.Inspect(f, func(nth ast.Node, s *scope.Scope) bool {
-		switch n := nth.(type) {
-		case *ast.Field:
-			maybeRemoveImportPrefix(&n.Type, prefix)
-		case *ast.StarExpr:
-			maybeRemoveImportPrefix(&n.X, prefix)
-		case *ast.Ellipsis:
-			maybeRemoveImportPrefix(&n.Elt, prefix)
-		case *ast.ArrayType:
-			maybeRemoveImportPrefix(&n.Elt, prefix)
-		case *ast.ChanType:
-			maybeRemoveImportPrefix(&n.Value, prefix)
-		case *ast.MapType:
-			maybeRemoveImportPrefix(&n.Key, prefix)
-		case *ast.CallExpr:
-			maybeRemoveImportPrefix(&n.Fun, prefix)
-		case *ast.ValueSpec:
-			maybeRemoveImportPrefix(&n.Type, prefix)
+	// NB(mmihic): Dot and blank imports have no references, so there are no
+	// qualifiers to remove.
+	idx := scope.NewIndexNamed(f, a.namer)
+	qualifiers := map[*ast.Ident]struct{}{}
+	for _, imp := range selfImports {
+		for _, ref := range idx.References(imp) {
+			qualifiers[ref] = struct{}{}
 		}
-		return true
-	})
-}
 
-func maybeRemoveImportPrefix(expr *ast.Expr, name string) {
-	if expr == nil {
-		return
-	}
+		var importName string
+		if imp.Name != nil {
+			importName = imp.Name.Name
+		}
 
-	sel, ok := (*expr).(*ast.SelectorExpr)
-	if !ok {
-		return
+		astutil.DeleteNamedImport(fset, f, importName, pkgPath.String())
 	}
 
-	ident, ok := sel.X.(*ast.Ident)
-	if !ok {
-		return
-	}
+	astutil.Apply(f, nil, func(c *astutil.Cursor) bool {
+		sel, ok := c.Node().(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
 
-	if ident.Name == name {
-		*expr = ast.NewIdent(sel.Sel.Name)
-	}
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if _, isQualifier := qualifiers[x]; isQualifier {
+				c.Replace(&ast.Ident{Name: sel.Sel.Name, NamePos: sel.Pos()})
+			}
+		}
+		return true
+	})
+
+	return true
 }
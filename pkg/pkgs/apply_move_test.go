@@ -9,6 +9,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/imports"
 	"github.com/mmihic/go-tools/pkg/path"
 )
 
@@ -46,11 +47,13 @@ func DoSomethingElse() string {
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package imports
 
 import (
-	other2 "github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/other"
 )
 
 func DoSomething() string {
@@ -63,6 +66,47 @@ func DoSomethingElse() string {
 	return other.DoSomething()
 }
 
+`, "\n"),
+		},
+
+		// ----------------
+		{
+			name:    "handles variables shadowing new package name at use",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+   "github.com/mmihic/go-tools/pkg/first"
+)
+
+func DoSomething() string {
+	var other Conflict
+	return first.DoSomething(other)
+}
+
+func DoSomethingElse() string {
+	return first.DoSomethingElse()
+}
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+			},
+			want: strings.TrimLeft(`
+package imports
+
+import (
+	other2 "github.com/mmihic/go-tools/pkg/other"
+)
+
+func DoSomething() string {
+	var other Conflict
+	return other2.DoSomething(other)
+}
+
+func DoSomethingElse() string {
+	return other2.DoSomethingElse()
+}
 `, "\n"),
 		},
 
@@ -83,7 +127,9 @@ func DoSomething() string { return "does something" }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 // Package other is a package that does some things.
 
 package other
@@ -108,7 +154,9 @@ func DoSomething() string { return "does something" }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 // Package other is a package that does some things. */
 
 package other
@@ -135,7 +183,9 @@ func DoSomething() string { return first.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package imports
 
 import (
@@ -170,7 +220,9 @@ func DoSomethingElise() string { return elise.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/first/something:github.com/mmihic/go-tools/pkg/newpkg",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package imports
 
 import (
@@ -204,7 +256,9 @@ func DoSomething() string { return first.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package first
 
 import (
@@ -235,7 +289,9 @@ func DoSomething() string { return first.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package main
 
 import (
@@ -268,7 +324,9 @@ func DoSomething() string { return first.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package main
 
 import (
@@ -303,7 +361,9 @@ func DoSomethingSecond() string { return second.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/second/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package main
 
 import (
@@ -337,7 +397,9 @@ func DoSomethingSecond() string { return second.DoSomething() }
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/second",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package main
 
 import (
@@ -385,7 +447,9 @@ func Prepare() {
 				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
 			},
 			want: strings.TrimLeft(`
+//go:build tools
 // +build tools
+
 package other
 
 type ArrayOfStuff []*Foo
@@ -396,17 +460,16 @@ type ChanOfStuff chan<- *Foo
 
 type Config struct {
 	Foo
-
 	more *Foo
 }
 
-func DoOtherThing(l ...Foo,) string { return DoSomething() }
+func DoOtherThing(l ...Foo) string { return DoSomething() }
 
 func DoSomethingElse() *Foo { return Wrap(DoOtherThing()) }
 
 func Prepare() {
 	var cfg Config
-	myVal := other.MyConstant
+	myVal := MyConstant
 }
 
 `, "\n"),
@@ -524,6 +587,220 @@ func Run() error {
 
 	x := config.X
 }
+`,
+		},
+		{
+			name:    "handles . import",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+	. "github.com/mmihic/go-tools/pkg/first"
+	"github.com/mmihic/go-tools/pkg/second"
+)
+
+func DoSomething() string { return second.Wrap(DoSomething()) }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+				"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/third",
+			},
+			want: `
+package imports
+
+import (
+	. "github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/third"
+)
+
+func DoSomething() string { return third.Wrap(DoSomething()) }
+`,
+		},
+		{
+			name:    "removes . self import",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+	"fmt"
+
+	. "github.com/mmihic/go-tools/pkg/first"
+)
+
+func DoSomething() string { return fmt.Sprint(DoOtherThing()) }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/imports:github.com/mmihic/go-tools/pkg/first",
+			},
+			want: `
+package first
+
+import (
+	"fmt"
+)
+
+func DoSomething() string { return fmt.Sprint(DoOtherThing()) }
+`,
+		},
+		{
+			name:    "removes aliased self import",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+	"fmt"
+
+	myfirst "github.com/mmihic/go-tools/pkg/first"
+)
+
+func DoSomething() string { return fmt.Sprint(myfirst.DoOtherThing()) }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/imports:github.com/mmihic/go-tools/pkg/first",
+			},
+			want: `
+package first
+
+import (
+	"fmt"
+)
+
+func DoSomething() string { return fmt.Sprint(DoOtherThing()) }
+`,
+		},
+		{
+			name:    "removes . import of package moving into this one",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+	"fmt"
+
+	. "github.com/mmihic/go-tools/pkg/first"
+)
+
+func DoSomething() string { return fmt.Sprint(DoOther()) }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/imports",
+			},
+			want: `
+package imports
+
+import (
+	"fmt"
+)
+
+func DoSomething() string { return fmt.Sprint(DoOther()) }
+`,
+		},
+		{
+			name:    "removes aliased import of package moving into this one",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+	"fmt"
+
+	f "github.com/mmihic/go-tools/pkg/first"
+)
+
+var defaultValue = f.Value
+
+func DoSomething() string { return fmt.Sprint(f.DoOther(defaultValue)) }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/imports",
+			},
+			want: `
+package imports
+
+import (
+	"fmt"
+)
+
+var defaultValue = Value
+
+func DoSomething() string { return fmt.Sprint(DoOther(defaultValue)) }
+`,
+		},
+		{
+			name:    "removes import of package moving into this one",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports
+
+import (
+	"fmt"
+
+	"github.com/mmihic/go-tools/pkg/first"
+)
+
+func DoSomething() string {
+	first := first.New()
+	return fmt.Sprint(first)
+}
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/imports",
+			},
+			want: `
+package imports
+
+import (
+	"fmt"
+)
+
+func DoSomething() string {
+	first := New()
+	return fmt.Sprint(first)
+}
+`,
+		},
+		{
+			name:    "removes import of package moving into this one below a moved prefix",
+			pkgPath: "github.com/mmihic/go-tools/pkg/first/sub",
+			src: `
+package sub
+
+import "github.com/mmihic/go-tools/pkg/second"
+
+func DoSomething() string { return second.DoOther() }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+				"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/other/sub",
+			},
+			want: `
+package sub
+
+func DoSomething() string { return DoOther() }
+`,
+		},
+		{
+			name:    "keeps import of package moving into the package under test",
+			pkgPath: "github.com/mmihic/go-tools/pkg/imports",
+			src: `
+package imports_test
+
+import "github.com/mmihic/go-tools/pkg/first"
+
+func TestDoOther() { first.DoOther() }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/imports",
+			},
+			want: `
+package imports_test
+
+import "github.com/mmihic/go-tools/pkg/imports"
+
+func TestDoOther() { imports.DoOther() }
 `,
 		},
 		{
@@ -564,6 +841,120 @@ import (
 func Run() error {
 	return nil
 }
+`,
+		},
+		{
+			name:    "leaves ignored generators in the package",
+			pkgPath: "github.com/mmihic/go-tools/pkg/first",
+			src: `
+//go:build ignore
+
+package main
+
+import "github.com/mmihic/go-tools/pkg/first"
+
+func main() { first.Generate() }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+			},
+			want: `
+//go:build ignore
+
+package main
+
+import "github.com/mmihic/go-tools/pkg/other"
+
+func main() { other.Generate() }
+`,
+		},
+		{
+			name:    "keeps the suffix of external test packages",
+			pkgPath: "github.com/mmihic/go-tools/pkg/first",
+			src: `
+package first_test
+
+import "github.com/mmihic/go-tools/pkg/first"
+
+var _ = first.DoSomething()
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+			},
+			want: `
+package other_test
+
+import "github.com/mmihic/go-tools/pkg/other"
+
+var _ = other.DoSomething()
+`,
+		},
+		{
+			name:    "leaves cgo preamble and //export comments in place",
+			pkgPath: "github.com/mmihic/go-tools/pkg/first",
+			src: `
+package first
+
+/*
+#cgo LDFLAGS: -lpng
+#include <png.h>
+*/
+import "C"
+
+import (
+	"github.com/mmihic/go-tools/pkg/other"
+	"github.com/mmihic/go-tools/pkg/second"
+)
+
+// Add adds.
+//
+//export Add
+func Add(a, b C.int) C.int { return other.Sum(second.Add(a, b)) }
+`,
+			rules: []string{
+				"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+				"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/C",
+			},
+			want: `
+package other
+
+/*
+#cgo LDFLAGS: -lpng
+#include <png.h>
+*/
+import "C"
+
+import (
+	C2 "github.com/mmihic/go-tools/pkg/C"
+)
+
+// Add adds.
+//
+//export Add
+func Add(a, b C.int) C.int { return Sum(C2.Add(a, b)) }
+`,
+		},
+		{
+			name:    "never rewrites the cgo import",
+			pkgPath: "github.com/mmihic/go-tools/pkg/first",
+			src: `
+package first
+
+// #include <stdlib.h>
+import "C"
+
+func Free(p *C.char) { C.free(p) }
+`,
+			rules: []string{
+				"C:github.com/mmihic/go-tools/pkg/other",
+			},
+			want: `
+package first
+
+// #include <stdlib.h>
+import "C"
+
+func Free(p *C.char) { C.free(p) }
 `,
 		},
 	} {
@@ -593,3 +984,197 @@ func Run() error {
 		})
 	}
 }
+
+func TestRewritePostMove_ResolvedPkgNames(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other/v2",
+		"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/go-second",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	existing := imports.PkgNameResolverFunc(func(importPath path.Path) (string, bool) {
+		if importPath.String() == "gopkg.in/yaml.v2" {
+			return "yaml", true
+		}
+		return "", false
+	})
+
+	applier := moves.Applier(imports.NewNamer(moves.PkgNames(existing), nil))
+
+	src := `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/first"
+	"github.com/mmihic/go-tools/pkg/second"
+	"gopkg.in/yaml.v2"
+)
+
+func DoSomething() string { return first.DoSomething(second.Do(yaml.Marshal)) }
+`
+
+	want := `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/go-second"
+	"github.com/mmihic/go-tools/pkg/other/v2"
+	"gopkg.in/yaml.v2"
+)
+
+func DoSomething() string { return other.DoSomething(second.Do(yaml.Marshal)) }
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = applier.Apply(fset, path.NewPath("github.com/mmihic/go-tools/cmd/main"), file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results, err := astio.String(fset, file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, strings.TrimSpace(want), strings.TrimSpace(results))
+}
+
+func TestRewritePostMove_AliasPolicy(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+		"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/config",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	applier := moves.Applier(imports.NewNamer(nil, &imports.AliasConfig{
+		Aliases: map[string]string{
+			"github.com/mmihic/go-tools/pkg/config": "gtconfig",
+		},
+		KeepExisting: true,
+	}))
+
+	src := `
+package main
+
+import (
+	legacy "github.com/mmihic/go-tools/pkg/first"
+	"github.com/mmihic/go-tools/pkg/second"
+)
+
+func DoSomething() string { return legacy.DoSomething(second.Do()) }
+`
+
+	want := `
+package main
+
+import (
+	gtconfig "github.com/mmihic/go-tools/pkg/config"
+	legacy "github.com/mmihic/go-tools/pkg/other"
+)
+
+func DoSomething() string { return legacy.DoSomething(gtconfig.Do()) }
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = applier.Apply(fset, path.NewPath("github.com/mmihic/go-tools/cmd/main"), file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	results, err := astio.String(fset, file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, strings.TrimSpace(want), strings.TrimSpace(results))
+}
+
+func TestRewritePostMove_Changes(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other",
+		"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/third",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	src := `
+package first
+
+import (
+	"github.com/mmihic/go-tools/pkg/other"
+	legacy "github.com/mmihic/go-tools/pkg/second"
+)
+
+func DoSomething() string { return legacy.DoSomething(other.Do()) }
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	changes, err := moves.ApplyChanges(fset, path.NewPath("github.com/mmihic/go-tools/pkg/first"), file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.True(t, changes.Changed())
+	assert.Equal(t, &FileChanges{
+		Imports: []*ImportChange{
+			{
+				OldPath:  "github.com/mmihic/go-tools/pkg/second",
+				NewPath:  "github.com/mmihic/go-tools/pkg/third",
+				OldAlias: "legacy",
+				Spec:     file.Imports[0],
+			},
+		},
+		Package:            &PackageChange{OldName: "first", NewName: "other"},
+		SelfImportsRemoved: []string{"github.com/mmihic/go-tools/pkg/other"},
+	}, changes)
+}
+
+func TestRewritePostMove_ChangesSamePackageName(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/internal/first",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	src := `
+package first
+
+func Do() {}
+`
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	changes, err := moves.ApplyChanges(fset, path.NewPath("github.com/mmihic/go-tools/pkg/first"), file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The package keeps its name, so nothing changed
+	assert.Nil(t, changes.Package)
+	assert.False(t, changes.Changed())
+}
@@ -0,0 +1,75 @@
+package pkgs
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"regexp"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// NB(mmihic): The assembler spells the period and slashes of a fully
+// qualified symbol such as example/pkg.Symbol with a middle dot and division
+// slashes, as example∕pkg·Symbol, and only allows identifier characters in
+// between. A symbol in the current package is written ·Symbol.
+const (
+	asmPkgSeparator  = "·"
+	asmPathSeparator = "∕"
+)
+
+var reAsmQualifiedSymbol = regexp.MustCompile(`[\p{L}_][\p{L}\p{Nd}_` + asmPathSeparator + `]*` + asmPkgSeparator)
+
+// RewriteAsm rewrites the fully qualified symbol references in Go assembly
+// source to reflect the moves. References to symbols in the current package
+// need no rewriting. Returns the rewritten source and true if any reference
+// was rewritten, failing with a RewriteError at the first reference to a
+// moved package that can't be spelled in assembly.
+func (moves Moves) RewriteAsm(src []byte) ([]byte, bool, error) {
+	var (
+		rewritten []byte
+		changed   bool
+		last      int
+	)
+
+	for _, loc := range reAsmQualifiedSymbol.FindAllIndex(src, -1) {
+		qualifier := string(src[loc[0]:loc[1]])
+		pkgPath := path.Path(strings.Split(strings.TrimSuffix(qualifier, asmPkgSeparator), asmPathSeparator))
+		mv := moves.BestMatch(pkgPath)
+		if mv == nil {
+			continue
+		}
+
+		newPath, _ := mv.Rewrite(pkgPath)
+		newQualifier := strings.Join(newPath, asmPathSeparator) + asmPkgSeparator
+		if reAsmQualifiedSymbol.FindString(newQualifier) != newQualifier {
+			return nil, false, &RewriteError{
+				Pos:   asmPosition(src, loc[0]),
+				Move:  mv,
+				Cause: fmt.Errorf("unable to refer to %s from assembly", newPath),
+			}
+		}
+
+		rewritten = append(rewritten, src[last:loc[0]]...)
+		rewritten = append(rewritten, newQualifier...)
+		last = loc[1]
+		changed = true
+	}
+
+	if !changed {
+		return src, false, nil
+	}
+
+	return append(rewritten, src[last:]...), true, nil
+}
+
+// asmPosition returns the line and column of the given offset in the source.
+func asmPosition(src []byte, offset int) token.Position {
+	line := bytes.Count(src[:offset], []byte("\n")) + 1
+	return token.Position{
+		Offset: offset,
+		Line:   line,
+		Column: offset - (bytes.LastIndexByte(src[:offset], '\n') + 1) + 1,
+	}
+}
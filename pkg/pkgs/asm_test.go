@@ -0,0 +1,55 @@
+package pkgs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoves_RewriteAsm(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"corp/src/first:corp/src/other",
+		"corp/src/second:corp/src/go-second",
+	})
+	require.NoError(t, err)
+
+	src := `#include "textflag.h"
+
+// func Add(a, b int) int
+TEXT ·Add(SB), NOSPLIT, $0-24
+	MOVQ a+0(FP), AX
+	ADDQ b+8(FP), AX
+	MOVQ AX, ret+16(FP)
+	CALL corp∕src∕first∕nested·helper(SB)
+	JMP corp∕src∕first·done(SB)
+	CALL corp∕src∕firstly·helper(SB)
+	JMP runtime·memmove(SB)
+`
+
+	rewritten, changed, err := moves.RewriteAsm([]byte(src))
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `#include "textflag.h"
+
+// func Add(a, b int) int
+TEXT ·Add(SB), NOSPLIT, $0-24
+	MOVQ a+0(FP), AX
+	ADDQ b+8(FP), AX
+	MOVQ AX, ret+16(FP)
+	CALL corp∕src∕other∕nested·helper(SB)
+	JMP corp∕src∕other·done(SB)
+	CALL corp∕src∕firstly·helper(SB)
+	JMP runtime·memmove(SB)
+`, string(rewritten))
+
+	unchanged, changed, err := Moves{}.RewriteAsm([]byte(src))
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, src, string(unchanged))
+
+	_, _, err = moves.RewriteAsm([]byte("\tRET\n\tCALL corp∕src∕second·helper(SB)\n"))
+	assert.EqualError(t, WithFilename("second_amd64.s", err),
+		"second_amd64.s:2:7: moving corp/src/second -> corp/src/go-second: "+
+			"unable to refer to corp/src/go-second from assembly")
+}
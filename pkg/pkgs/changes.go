@@ -0,0 +1,48 @@
+package pkgs
+
+import "go/ast"
+
+// FileChanges describes the changes made to a file by applying moves.
+type FileChanges struct {
+	// Imports are the imports that were rewritten.
+	Imports []*ImportChange `json:"imports,omitempty"`
+
+	// Package is the change to the package clause, if the file's package was moved.
+	Package *PackageChange `json:"package,omitempty"`
+
+	// SelfImportsRemoved are the imports removed because they now refer to the
+	// file's own package.
+	SelfImportsRemoved []string `json:"self_imports_removed,omitempty"`
+}
+
+// RewrittenImports returns the import specs that were rewritten.
+func (c *FileChanges) RewrittenImports() []*ast.ImportSpec {
+	specs := make([]*ast.ImportSpec, 0, len(c.Imports))
+	for _, imp := range c.Imports {
+		specs = append(specs, imp.Spec)
+	}
+	return specs
+}
+
+// Changed returns true if any changes were made.
+func (c *FileChanges) Changed() bool {
+	return len(c.Imports) != 0 || c.Package != nil
+}
+
+// An ImportChange describes the rewrite of an import. Aliases are empty for
+// imports referred to by the name of the imported package.
+type ImportChange struct {
+	OldPath  string `json:"old_path"`
+	NewPath  string `json:"new_path"`
+	OldAlias string `json:"old_alias,omitempty"`
+	NewAlias string `json:"new_alias,omitempty"`
+
+	// Spec is the rewritten import.
+	Spec *ast.ImportSpec `json:"-"`
+}
+
+// A PackageChange describes the rename of a file's package clause.
+type PackageChange struct {
+	OldName string `json:"old_name"`
+	NewName string `json:"new_name"`
+}
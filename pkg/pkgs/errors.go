@@ -0,0 +1,117 @@
+package pkgs
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// A RewriteError is an error rewriting a file to reflect the moves.
+type RewriteError struct {
+	// Filename is the file being rewritten.
+	Filename string
+
+	// Pos is the position of the error within the file, with a zero line if
+	// the error isn't specific to any position.
+	Pos token.Position
+
+	// Move is the move being applied, if the error is specific to one.
+	Move *Move
+
+	// Cause is the underlying error.
+	Cause error
+}
+
+// Error returns the error prefixed with its position and move, if any.
+func (e *RewriteError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Filename)
+	if e.Pos.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", e.Pos.Line, e.Pos.Column)
+	}
+
+	if e.Move != nil {
+		fmt.Fprintf(&b, ": moving %s -> %s", e.Move.From, e.Move.To)
+	}
+
+	fmt.Fprintf(&b, ": %v", e.Cause)
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *RewriteError) Unwrap() error {
+	return e.Cause
+}
+
+// WithFilename returns err as a RewriteError in the named file. Errors that are
+// already RewriteErrors keep their position and move, and gain the file name
+// if they lack one.
+func WithFilename(fname string, err error) *RewriteError {
+	rwErr, ok := err.(*RewriteError)
+	if !ok {
+		return &RewriteError{Filename: fname, Cause: err}
+	}
+
+	if rwErr.Filename == "" {
+		rwErr.Filename = fname
+		rwErr.Pos.Filename = fname
+	}
+
+	return rwErr
+}
+
+// ParseErrors converts the error returned by the parser into a RewriteError
+// per line with syntax errors, applying the given move if any.
+func ParseErrors(fname string, mv *Move, err error) []error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return []error{&RewriteError{Filename: fname, Move: mv, Cause: err}}
+	}
+
+	// NB(mmihic): As with the go tool, only the first error on each line is
+	// kept, since the rest tend to follow from it.
+	list.RemoveMultiples()
+
+	errs := make([]error, 0, len(list))
+	for _, e := range list {
+		errs = append(errs, &RewriteError{
+			Filename: fname,
+			Pos:      e.Pos,
+			Move:     mv,
+			Cause:    fmt.Errorf("syntax error: %s", e.Msg),
+		})
+	}
+
+	return errs
+}
+
+// SortErrors sorts errors in a deterministic order: RewriteErrors by file and
+// position, followed by any others by message.
+func SortErrors(errs []error) {
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, aOK := errs[i].(*RewriteError)
+		b, bOK := errs[j].(*RewriteError)
+		switch {
+		case aOK && bOK:
+			if a.Filename != b.Filename {
+				return a.Filename < b.Filename
+			}
+
+			if a.Pos.Line != b.Pos.Line {
+				return a.Pos.Line < b.Pos.Line
+			}
+
+			if a.Pos.Column != b.Pos.Column {
+				return a.Pos.Column < b.Pos.Column
+			}
+
+			return a.Cause.Error() < b.Cause.Error()
+		case aOK != bOK:
+			return aOK
+		default:
+			return errs[i].Error() < errs[j].Error()
+		}
+	})
+}
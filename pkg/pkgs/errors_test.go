@@ -0,0 +1,87 @@
+package pkgs
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	mv, err := ParseMove("pkg/first:pkg/other")
+	require.NoError(t, err)
+
+	src := `package first
+
+import "pkg/first"
+
+func broken( {
+}
+
+var x =
+`
+
+	fset := token.NewFileSet()
+	_, err = parser.ParseFile(fset, "broken.go", src, parser.AllErrors)
+	require.Error(t, err)
+
+	// NB(mmihic): The parser's messages vary between Go versions, so only the
+	// positions and moves are checked.
+	errs := ParseErrors("broken.go", mv, err)
+	require.True(t, len(errs) > 1, "expected all syntax errors, got %v", errs)
+	for _, err := range errs {
+		rwErr, ok := err.(*RewriteError)
+		require.True(t, ok)
+		assert.Equal(t, "broken.go", rwErr.Filename)
+		assert.Equal(t, mv, rwErr.Move)
+		assert.Contains(t, rwErr.Cause.Error(), "syntax error: ")
+	}
+	assert.Equal(t, 5, errs[0].(*RewriteError).Pos.Line)
+	assert.Equal(t, 14, errs[0].(*RewriteError).Pos.Column)
+
+	assert.Equal(t, []error{&RewriteError{Filename: "broken.go", Cause: errors.New("oops")}},
+		ParseErrors("broken.go", nil, errors.New("oops")))
+}
+
+func TestSortErrors(t *testing.T) {
+	errs := []error{
+		errors.New("unable to write journal"),
+		&RewriteError{Filename: "b.go", Pos: token.Position{Line: 2, Column: 1}, Cause: errors.New("second")},
+		errors.New("go generate failed"),
+		&RewriteError{Filename: "b.go", Pos: token.Position{Line: 1, Column: 5}, Cause: errors.New("first")},
+		&RewriteError{Filename: "a.go", Pos: token.Position{Line: 9, Column: 1}, Cause: errors.New("third")},
+		&RewriteError{Filename: "b.go", Cause: errors.New("unpositioned")},
+	}
+
+	SortErrors(errs)
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	assert.Equal(t, []string{
+		"a.go:9:1: third",
+		"b.go: unpositioned",
+		"b.go:1:5: first",
+		"b.go:2:1: second",
+		"go generate failed",
+		"unable to write journal",
+	}, msgs)
+}
+
+func TestWithFilename(t *testing.T) {
+	wrapped := WithFilename("a.go", errors.New("unable to read"))
+	assert.EqualError(t, wrapped, "a.go: unable to read")
+	assert.EqualError(t, errors.Unwrap(wrapped), "unable to read")
+
+	positioned := &RewriteError{Pos: token.Position{Line: 3, Column: 2}, Cause: errors.New("bad")}
+	assert.Equal(t, positioned, WithFilename("a.s", positioned))
+	assert.EqualError(t, positioned, "a.s:3:2: bad")
+	assert.Equal(t, "a.s", positioned.Pos.Filename)
+
+	assert.EqualError(t, WithFilename("b.s", positioned), "a.s:3:2: bad")
+}
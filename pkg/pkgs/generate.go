@@ -0,0 +1,240 @@
+package pkgs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A GenerateDirective is a go:generate directive in a Go file.
+type GenerateDirective struct {
+	// Filename is the name of the file holding the directive.
+	Filename string
+
+	// Line is the line of the directive within the file.
+	Line int
+
+	// Text is the text of the directive, as matched by go generate -run.
+	Text string
+
+	// Args are the words of the command run by the directive, before
+	// environment variables are expanded.
+	Args []string
+}
+
+// String returns the position and text of the directive.
+func (d *GenerateDirective) String() string {
+	return fmt.Sprintf("%s:%d: %s", d.Filename, d.Line, d.Text)
+}
+
+// RunPattern returns the go generate -run pattern matching only the directive.
+func (d *GenerateDirective) RunPattern() string {
+	return "^" + regexp.QuoteMeta(d.Text) + "$"
+}
+
+// ReadGenerateDirectives returns the go:generate directives in the Go files in
+// the given directory that build for the current platform, in the order go
+// generate runs them.
+func ReadGenerateDirectives(dir string) ([]*GenerateDirective, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var directives []*GenerateDirective
+	for _, info := range infos {
+		if info.IsDir() || filepath.Ext(info.Name()) != ".go" {
+			continue
+		}
+
+		if match, err := build.Default.MatchFile(dir, info.Name()); err != nil || !match {
+			continue
+		}
+
+		fname := filepath.Join(dir, info.Name())
+		src, err := ioutil.ReadFile(fname)
+		if err != nil {
+			return nil, err
+		}
+
+		directives = append(directives, ParseGenerateDirectives(fname, src)...)
+	}
+
+	return directives, nil
+}
+
+// ParseGenerateDirectives returns the go:generate directives in the given
+// source. Directives with malformed quoting are ignored, as go generate would
+// refuse to run them.
+func ParseGenerateDirectives(fname string, src []byte) []*GenerateDirective {
+	var directives []*GenerateDirective
+
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(nil, len(src)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if !strings.HasPrefix(text, "//go:generate ") && !strings.HasPrefix(text, "//go:generate\t") {
+			continue
+		}
+
+		args, ok := splitGenerateArgs(text[len("//go:generate "):])
+		if !ok || len(args) == 0 {
+			continue
+		}
+
+		directives = append(directives, &GenerateDirective{
+			Filename: fname,
+			Line:     line,
+			Text:     strings.TrimSpace(text),
+			Args:     args,
+		})
+	}
+
+	return directives
+}
+
+// splitGenerateArgs splits the command of a go:generate directive into words,
+// as go generate does.
+func splitGenerateArgs(cmd string) ([]string, bool) {
+	var args []string
+	for {
+		cmd = strings.TrimLeft(cmd, " \t\r")
+		if cmd == "" {
+			return args, true
+		}
+
+		end := strings.IndexAny(cmd, " \t\r")
+		if end < 0 {
+			end = len(cmd)
+		}
+
+		if cmd[0] == '"' {
+			for end = 1; end < len(cmd) && cmd[end] != '"'; end++ {
+				if cmd[end] == '\\' {
+					end++
+				}
+			}
+
+			if end >= len(cmd) {
+				return nil, false
+			}
+			end++
+		}
+
+		arg := cmd[:end]
+		if arg[0] == '"' {
+			var err error
+			if arg, err = strconv.Unquote(arg); err != nil {
+				return nil, false
+			}
+		}
+
+		args = append(args, arg)
+		cmd = cmd[end:]
+	}
+}
+
+// reGeneratedBy extracts the generator from the comment marking a generated
+// file.
+var reGeneratedBy = regexp.MustCompile(`^// Code generated (?:by )?(.*?)[.;]? DO NOT EDIT\.$`)
+
+// GeneratedBy returns the generator named by the comment marking the file as
+// generated, such as protoc-gen-go or stringer -type=Kind, or an empty string
+// if the file isn't generated.
+func GeneratedBy(f *ast.File) string {
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+
+		for _, c := range cg.List {
+			if m := reGeneratedBy.FindStringSubmatch(c.Text); m != nil {
+				return strings.Trim(m[1], `"`)
+			}
+		}
+	}
+
+	return ""
+}
+
+// DirectivesGenerating returns the directives that regenerate the named file,
+// given the generator that produced it. Directives naming the file, or whose
+// command is exactly the generator, are preferred over directives that merely
+// run the same generator. Generated files don't record the directive that
+// produced them, so this is a best effort.
+func DirectivesGenerating(directives []*GenerateDirective, fname, generatedBy string) []*GenerateDirective {
+	var exact, sameGenerator []*GenerateDirective
+
+	generator := generatorName(strings.Fields(generatedBy))
+	for _, d := range directives {
+		switch {
+		case strings.Join(d.Args, " ") == generatedBy || namesFile(d.Args, fname):
+			exact = append(exact, d)
+		case generator != "" && runsGenerator(generatorName(d.Args), generator):
+			sameGenerator = append(sameGenerator, d)
+		}
+	}
+
+	matched := exact
+	if len(matched) == 0 {
+		matched = sameGenerator
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		if matched[i].Filename != matched[j].Filename {
+			return matched[i].Filename < matched[j].Filename
+		}
+		return matched[i].Line < matched[j].Line
+	})
+	return matched
+}
+
+// generatorName returns the name of the program run by a command, which for
+// go run is the program being run.
+func generatorName(args []string) string {
+	if len(args) > 1 && args[0] == "go" && args[1] == "run" {
+		args = args[2:]
+		for len(args) != 0 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+	}
+
+	if len(args) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSuffix(filepath.Base(args[0]), ".go"))
+}
+
+// runsGenerator returns true if the named command is the given generator, or
+// drives it as a plugin, as protoc drives protoc-gen-go.
+func runsGenerator(cmd, generator string) bool {
+	return cmd != "" && (cmd == generator || strings.HasPrefix(generator, cmd+"-"))
+}
+
+// namesFile returns true if any of the arguments, or the values of any flags,
+// name the given file.
+func namesFile(args []string, fname string) bool {
+	base := filepath.Base(fname)
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			if i := strings.Index(arg, "="); i >= 0 {
+				arg = arg[i+1:]
+			}
+		}
+
+		if filepath.Base(filepath.FromSlash(arg)) == base {
+			return true
+		}
+	}
+
+	return false
+}
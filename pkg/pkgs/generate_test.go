@@ -0,0 +1,92 @@
+package pkgs
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGenerateDirectives(t *testing.T) {
+	directives := ParseGenerateDirectives("kind.go", []byte(`package first
+
+//go:generate stringer -type=Kind
+//go:generate	mockgen -destination "mock dir/mock_kind.go" . Kinder
+// go:generate not a directive
+//go:generate broken "quoting
+
+type Kind int
+`))
+
+	require.Len(t, directives, 2)
+	assert.Equal(t, &GenerateDirective{
+		Filename: "kind.go",
+		Line:     3,
+		Text:     "//go:generate stringer -type=Kind",
+		Args:     []string{"stringer", "-type=Kind"},
+	}, directives[0])
+	assert.Equal(t, []string{"mockgen", "-destination", "mock dir/mock_kind.go", ".", "Kinder"}, directives[1].Args)
+	assert.Equal(t, 4, directives[1].Line)
+	assert.Equal(t, "kind.go:3: //go:generate stringer -type=Kind", directives[0].String())
+	assert.Equal(t, `^//go:generate stringer -type=Kind$`, directives[0].RunPattern())
+}
+
+func TestGeneratedBy(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want string
+	}{
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: first.proto\n\npackage first\n", "protoc-gen-go"},
+		{"// Code generated by \"stringer -type=Kind\"; DO NOT EDIT.\n\npackage first\n", "stringer -type=Kind"},
+		{"// Code generated by MockGen. DO NOT EDIT.\npackage first\n", "MockGen"},
+		{"// Code generated DO NOT EDIT.\npackage first\n", ""},
+		{"// Package first does things.\npackage first\n", ""},
+	} {
+		f, err := parser.ParseFile(token.NewFileSet(), "", tt.src, parser.ParseComments|parser.PackageClauseOnly)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, GeneratedBy(f), tt.src)
+	}
+}
+
+func TestDirectivesGenerating(t *testing.T) {
+	directives := ParseGenerateDirectives("first/gen.go", []byte(`package first
+
+//go:generate stringer -type=Kind
+//go:generate stringer -type=Color
+//go:generate mockgen -destination=mock_kinder.go . Kinder
+//go:generate protoc --go_out=. first.proto
+//go:generate go run -mod=mod ./cmd/gentables
+`))
+
+	texts := func(matched []*GenerateDirective) []string {
+		var texts []string
+		for _, d := range matched {
+			texts = append(texts, d.Text)
+		}
+		return texts
+	}
+
+	for _, tt := range []struct {
+		name        string
+		fname       string
+		generatedBy string
+		want        []string
+	}{
+		{"exact command", "first/kind_string.go", "stringer -type=Kind",
+			[]string{"//go:generate stringer -type=Kind"}},
+		{"same generator", "first/kind_string.go", "stringer -type=Kind,Color -linecomment",
+			[]string{"//go:generate stringer -type=Kind", "//go:generate stringer -type=Color"}},
+		{"names the file", "first/mock_kinder.go", "MockGen",
+			[]string{"//go:generate mockgen -destination=mock_kinder.go . Kinder"}},
+		{"plugin", "first/first.pb.go", "protoc-gen-go",
+			[]string{"//go:generate protoc --go_out=. first.proto"}},
+		{"go run", "first/tables.go", "gentables",
+			[]string{"//go:generate go run -mod=mod ./cmd/gentables"}},
+		{"unknown", "first/other.go", "something-else", nil},
+		{"no generator", "first/other.go", "", nil},
+	} {
+		assert.Equal(t, tt.want, texts(DirectivesGenerating(directives, tt.fname, tt.generatedBy)), tt.name)
+	}
+}
@@ -0,0 +1,105 @@
+package pkgs
+
+import (
+	"fmt"
+	"go/ast"
+	"regexp"
+)
+
+// A GeneratedPolicy determines how generated files affected by a move are
+// handled.
+type GeneratedPolicy string
+
+// Generated file policies, in increasing order of precedence.
+const (
+	// GeneratedRewrite rewrites generated files like any other file. This is
+	// the default.
+	GeneratedRewrite GeneratedPolicy = "rewrite"
+
+	// GeneratedRegenerate regenerates generated files after the move, by
+	// running the go:generate directives that produce them. The files are
+	// rewritten first, and left as rewritten if their generators fail.
+	GeneratedRegenerate GeneratedPolicy = "generate"
+
+	// GeneratedSkip leaves generated files as they are, with a warning.
+	GeneratedSkip GeneratedPolicy = "skip"
+)
+
+var generatedPolicyRank = map[GeneratedPolicy]int{
+	"":                  0,
+	GeneratedRewrite:    0,
+	GeneratedRegenerate: 1,
+	GeneratedSkip:       2,
+}
+
+// Validate checks that the policy is known.
+func (p GeneratedPolicy) Validate() error {
+	if _, ok := generatedPolicyRank[p]; !ok {
+		return fmt.Errorf("invalid generated file policy %s, must be one of %s, %s or %s",
+			p, GeneratedRewrite, GeneratedRegenerate, GeneratedSkip)
+	}
+	return nil
+}
+
+// UnmarshalYAML unmarshals and validates the policy.
+func (p *GeneratedPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	if err := GeneratedPolicy(s).Validate(); err != nil {
+		return err
+	}
+
+	*p = GeneratedPolicy(s)
+	return nil
+}
+
+// WithGeneratedPolicy returns the moves, with the given policy applied to those
+// that don't specify one of their own.
+func (moves Moves) WithGeneratedPolicy(p GeneratedPolicy) Moves {
+	withPolicy := make(Moves, len(moves))
+	for i, mv := range moves {
+		copied := *mv
+		if copied.Generated == "" {
+			copied.Generated = p
+		}
+		withPolicy[i] = &copied
+	}
+	return withPolicy
+}
+
+// GeneratedPolicy returns the policy for generated files affected by all of
+// the moves, which is the policy of highest precedence among them: skipping
+// wins over regenerating, which wins over rewriting.
+func (moves Moves) GeneratedPolicy() GeneratedPolicy {
+	policy := GeneratedRewrite
+	for _, mv := range moves {
+		if generatedPolicyRank[mv.Generated] > generatedPolicyRank[policy] {
+			policy = mv.Generated
+		}
+	}
+	return policy
+}
+
+// reGenerated matches the comment marking a generated file, per
+// https://golang.org/s/generatedcode.
+var reGenerated = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// IsGenerated returns true if the file is marked as generated.
+func IsGenerated(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+
+		for _, c := range cg.List {
+			if reGenerated.MatchString(c.Text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,97 @@
+package pkgs
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestIsGenerated(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{"hand written", "// Package first does things.\npackage first\n", false},
+		{"protobuf", "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: first.proto\n\npackage first\n", true},
+		{"after build constraint", "//go:build linux\n\n// Code generated by \"stringer -type=Kind\"; DO NOT EDIT.\n\npackage first\n", true},
+		{"after package clause", "package first\n\n// Code generated by mockgen. DO NOT EDIT.\n", false},
+		{"not the marker", "// Code generated by hand, feel free to edit.\npackage first\n", false},
+	} {
+		f, err := parser.ParseFile(token.NewFileSet(), "", tt.src, parser.ParseComments|parser.PackageClauseOnly)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, IsGenerated(f), tt.name)
+	}
+}
+
+func TestMoves_GeneratedPolicy(t *testing.T) {
+	moves := Moves{
+		{From: path.NewPath("pkg/first"), To: path.NewPath("pkg/other")},
+		{From: path.NewPath("pkg/second"), To: path.NewPath("pkg/third"), Generated: GeneratedRegenerate},
+		{From: path.NewPath("pkg/fourth"), To: path.NewPath("pkg/fifth"), Generated: GeneratedSkip},
+	}
+
+	assert.Equal(t, GeneratedRewrite, Moves{}.GeneratedPolicy())
+	assert.Equal(t, GeneratedRewrite, moves[:1].GeneratedPolicy())
+	assert.Equal(t, GeneratedRegenerate, moves[:2].GeneratedPolicy())
+	assert.Equal(t, GeneratedSkip, moves.GeneratedPolicy())
+
+	withDefault := moves.WithGeneratedPolicy(GeneratedSkip)
+	assert.Equal(t, GeneratedSkip, withDefault[0].Generated)
+	assert.Equal(t, GeneratedRegenerate, withDefault[1].Generated)
+	assert.Equal(t, GeneratedPolicy(""), moves[0].Generated)
+}
+
+func TestMoves_Affecting(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"pkg/first:pkg/other",
+		"pkg/second:pkg/third",
+		"pkg/unused:pkg/elsewhere",
+	})
+	require.NoError(t, err)
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", `
+package first
+
+import (
+	"fmt"
+
+	"pkg/second/nested"
+)
+`, parser.ImportsOnly)
+	require.NoError(t, err)
+
+	affecting := moves.Affecting(path.NewPath("pkg/first"), f)
+	require.Len(t, affecting, 2)
+	assert.Equal(t, path.NewPath("pkg/first"), affecting[0].From)
+	assert.Equal(t, path.NewPath("pkg/second"), affecting[1].From)
+
+	assert.Empty(t, moves.Affecting(path.NewPath("pkg/fourth"), &ast.File{Name: ast.NewIdent("fourth")}))
+}
+
+func TestMoves_YAMLGeneratedPolicy(t *testing.T) {
+	var moves Moves
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- pkg/second:pkg/third
+- from: pkg/first
+  to: pkg/other
+  generated: generate
+`), &moves))
+
+	require.Len(t, moves, 2)
+	assert.Equal(t, GeneratedRegenerate, moves[0].Generated)
+	assert.Equal(t, GeneratedPolicy(""), moves[1].Generated)
+
+	contents, err := yaml.Marshal(moves)
+	require.NoError(t, err)
+	assert.Equal(t, "- from: pkg/first\n  to: pkg/other\n  generated: generate\n- pkg/second:pkg/third\n", string(contents))
+
+	assert.Error(t, yaml.Unmarshal([]byte("- {from: pkg/first, to: pkg/other, generated: sometimes}\n"), &moves))
+}
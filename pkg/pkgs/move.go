@@ -2,9 +2,12 @@ package pkgs
 
 import (
 	"fmt"
+	"go/ast"
 	"sort"
 	"strings"
 
+	"github.com/mmihic/go-tools/pkg/ident"
+	"github.com/mmihic/go-tools/pkg/imports"
 	"github.com/mmihic/go-tools/pkg/path"
 )
 
@@ -12,13 +15,32 @@ import (
 type Move struct {
 	From path.Path `yaml:"from"`
 	To   path.Path `yaml:"to"`
+
+	// Generated is the policy for generated files affected by the move.
+	Generated GeneratedPolicy `yaml:"generated,omitempty"`
+}
+
+// movePolicy is the YAML form of a move with a policy for generated files.
+type movePolicy struct {
+	From      string          `yaml:"from"`
+	To        string          `yaml:"to"`
+	Generated GeneratedPolicy `yaml:"generated,omitempty"`
 }
 
-// UnmarshalYAML unmarshals the package move from YAML
+// UnmarshalYAML unmarshals the package move from YAML, either in the form
+// accepted by ParseMove, or as a mapping with from, to, and generated keys.
 func (mv *Move) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var s string
+	var (
+		s          string
+		withPolicy movePolicy
+	)
+
 	if err := unmarshal(&s); err != nil {
-		return err
+		if err := unmarshal(&withPolicy); err != nil {
+			return err
+		}
+
+		s = fmt.Sprintf("%s:%s", withPolicy.From, withPolicy.To)
 	}
 
 	parsed, err := ParseMove(s)
@@ -26,14 +48,26 @@ func (mv *Move) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		return err
 	}
 
+	parsed.Generated = withPolicy.Generated
 	*mv = *parsed
 	return nil
 }
 
+// MarshalYAML marshals the package move to YAML, in the form accepted by
+// ParseMove unless it has a policy for generated files.
+func (mv *Move) MarshalYAML() (interface{}, error) {
+	from, to := strings.Join(mv.From, "/"), strings.Join(mv.To, "/")
+	if mv.Generated == "" || mv.Generated == GeneratedRewrite {
+		return fmt.Sprintf("%s:%s", from, to), nil
+	}
+
+	return &movePolicy{From: from, To: to, Generated: mv.Generated}, nil
+}
+
 // ParseMove parses a package move.
 func ParseMove(s string) (*Move, error) {
 	parts := strings.Split(s, ":")
-	if len(parts) != 2 {
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
 		return nil, fmt.Errorf("invalid package move %s", s)
 	}
 
@@ -63,11 +97,17 @@ func (mv *Move) Rewrite(path path.Path) (path.Path, error) {
 	return mv.To.Append(path[len(mv.From):]), nil
 }
 
+// PkgName returns the name given to the package once it has been moved.
+func (mv *Move) PkgName() string {
+	return ident.FromImportPath(mv.To)
+}
+
 // ApplyPrefix applies a prefix to the rules.
 func (mv *Move) ApplyPrefix(prefix path.Path) *Move {
 	return &Move{
-		From: prefix.Append(mv.From),
-		To:   prefix.Append(mv.To),
+		From:      prefix.Append(mv.From),
+		To:        prefix.Append(mv.To),
+		Generated: mv.Generated,
 	}
 }
 
@@ -92,16 +132,12 @@ func ParseMoves(mvList []string) (Moves, error) {
 
 // UnmarshalYAML unmarshals a set of rules from YAML.
 func (moves *Moves) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var mvList []string
-	if err := unmarshal(&mvList); err != nil {
-		return err
-	}
-
-	parsed, err := ParseMoves(mvList)
-	if err != nil {
+	var parsed []*Move
+	if err := unmarshal(&parsed); err != nil {
 		return err
 	}
 
+	sort.Sort(Moves(parsed))
 	*moves = parsed
 	return nil
 }
@@ -155,6 +191,24 @@ func (moves Moves) ExactMatch(p path.Path) *Move {
 	return nil
 }
 
+// Affecting returns the moves that apply to the given file in the package at
+// the given path: the move of the package itself, and the moves of the
+// packages it imports.
+func (moves Moves) Affecting(pkgPath path.Path, f *ast.File) Moves {
+	var affecting Moves
+	if mv := moves.ExactMatch(pkgPath); mv != nil {
+		affecting = append(affecting, mv)
+	}
+
+	for _, imp := range f.Imports {
+		if mv := moves.BestMatch(imports.Path(imp)); mv != nil && !imports.IsCgo(imp) {
+			affecting = append(affecting, mv)
+		}
+	}
+
+	return affecting
+}
+
 // ApplyPrefix applies a prefix to all rules, returning a new set of rules
 func (moves Moves) ApplyPrefix(prefix path.Path) Moves {
 	newMoves := make(Moves, len(moves))
@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 
+	"github.com/mmihic/go-tools/pkg/imports"
 	"github.com/mmihic/go-tools/pkg/path"
 )
 
@@ -78,3 +80,54 @@ func TestMoves_ExactMatch(t *testing.T) {
 	match = rules.BestMatch(path.NewPath("github.com/mmihic/go-tools/cmd/othertool"))
 	require.Nil(t, match)
 }
+
+func TestMoves_PkgNames(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"github.com/mmihic/go-tools/pkg/first:github.com/mmihic/go-tools/pkg/other/v2",
+		"github.com/mmihic/go-tools/pkg/second:github.com/mmihic/go-tools/pkg/go-third",
+	})
+	require.NoError(t, err)
+
+	existing := imports.PkgNameResolverFunc(func(importPath path.Path) (string, bool) {
+		switch importPath.String() {
+		case "github.com/mmihic/go-tools/pkg/first/go-nested":
+			return "nested", true
+		case "github.com/mmihic/go-tools/pkg/unmoved":
+			return "notmoved", true
+		}
+		return "", false
+	})
+
+	r := moves.PkgNames(existing)
+	for _, tt := range []struct {
+		importPath string
+		want       string
+		wantOK     bool
+	}{
+		{"github.com/mmihic/go-tools/pkg/other/v2", "other", true},
+		{"github.com/mmihic/go-tools/pkg/go-third", "third", true},
+		{"github.com/mmihic/go-tools/pkg/other/v2/go-nested", "nested", true},
+		{"github.com/mmihic/go-tools/pkg/unmoved", "notmoved", true},
+		{"github.com/mmihic/go-tools/pkg/missing", "", false},
+	} {
+		name, ok := r.PkgName(path.NewPath(tt.importPath))
+		require.Equal(t, tt.wantOK, ok, tt.importPath)
+		require.Equal(t, tt.want, name, tt.importPath)
+	}
+}
+
+func TestMoves_YAML(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"pkg/first:pkg/other",
+		"pkg/second:pkg/third/v2",
+	})
+	require.NoError(t, err)
+
+	contents, err := yaml.Marshal(moves)
+	require.NoError(t, err)
+	require.Equal(t, "- pkg/first:pkg/other\n- pkg/second:pkg/third/v2\n", string(contents))
+
+	var parsed Moves
+	require.NoError(t, yaml.Unmarshal(contents, &parsed))
+	require.Equal(t, moves, parsed)
+}
@@ -0,0 +1,43 @@
+package pkgs
+
+import (
+	"github.com/mmihic/go-tools/pkg/imports"
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// PkgNames returns a resolver for the names of packages as they will be once
+// the moves have been applied, using the given resolver to find the names of
+// the packages as they exist today.
+func (moves Moves) PkgNames(r imports.PkgNameResolver) imports.PkgNameResolver {
+	return imports.PkgNameResolverFunc(func(importPath path.Path) (string, bool) {
+		// Packages moved to exactly this path are renamed to match the path
+		for _, mv := range moves {
+			if mv.To.Equal(importPath) {
+				return mv.PkgName(), true
+			}
+		}
+
+		// Packages moved underneath this path keep their existing name
+		if mv := moves.bestMatchTo(importPath); mv != nil {
+			original := mv.From.Append(importPath[len(mv.To):])
+			if name, ok := r.PkgName(original); ok {
+				return name, true
+			}
+		}
+
+		return r.PkgName(importPath)
+	})
+}
+
+// bestMatchTo returns the move that most specifically moves packages into the
+// given path, or nil if no moves do.
+func (moves Moves) bestMatchTo(p path.Path) *Move {
+	var best *Move
+	for _, mv := range moves {
+		if mv.To.Contains(p) && (best == nil || len(mv.To) > len(best.To)) {
+			best = mv
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,61 @@
+package pkgs
+
+import (
+	"bytes"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// A Prefilter rules out the Go files that can't need rewriting without parsing
+// them, by scanning their source for anything that could be an import of a
+// moved package. It is safe for concurrent use.
+type Prefilter struct {
+	moves Moves
+
+	// needles are the quoted forms of the moved paths, which appear in the
+	// source of any file importing them
+	needles [][]byte
+}
+
+// Prefilter returns a Prefilter for the moves.
+func (moves Moves) Prefilter() *Prefilter {
+	p := &Prefilter{moves: moves}
+	for _, mv := range moves {
+		// NB(mmihic): Import paths may be interpreted or raw string literals.
+		from := mv.From.String()
+		p.needles = append(p.needles, []byte(`"`+from), []byte("`"+from))
+	}
+
+	return p
+}
+
+// MayAffect returns true if the moves may affect the Go file with the given
+// source in the package at the given path, because the package itself has
+// moved or the source mentions a moved package in a string literal. Files
+// for which it returns false can't need rewriting, while those for which it
+// returns true need parsing to be sure.
+func (p *Prefilter) MayAffect(pkgPath path.Path, src []byte) bool {
+	if p.moves.ExactMatch(pkgPath) != nil {
+		return true
+	}
+
+	// NB(mmihic): An import path spelled with escape sequences, such as
+	// "example.com/\x66oo", would be missed, but such imports aren't written in
+	// practice.
+	for _, needle := range p.needles {
+		for rest := src; ; {
+			i := bytes.Index(rest, needle)
+			if i < 0 {
+				break
+			}
+
+			// Only whole path elements match, so pkg/foo doesn't match pkg/foobar
+			rest = rest[i+len(needle):]
+			if len(rest) != 0 && (rest[0] == '/' || rest[0] == needle[0]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
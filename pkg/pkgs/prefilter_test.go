@@ -0,0 +1,158 @@
+package pkgs
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestPrefilter_MayAffect(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"corp/src/first:corp/src/other",
+		"corp/src/second/nested:corp/src/third",
+	})
+	require.NoError(t, err)
+
+	p := moves.Prefilter()
+	tests := []struct {
+		name    string
+		pkgPath string
+		src     string
+		want    bool
+	}{
+		{"moved package", "corp/src/first", "package first\n", true},
+		{"nested moved package", "corp/src/first/nested", "package nested\n", false},
+		{"no imports", "corp/src/main", "package main\n", false},
+		{"unrelated import", "corp/src/main", "package main\n\nimport \"corp/src/firstly\"\n", false},
+		{"import", "corp/src/main", "package main\n\nimport \"corp/src/first\"\n", true},
+		{"aliased import", "corp/src/main", "package main\n\nimport f \"corp/src/first\"\n", true},
+		{"import of nested package", "corp/src/main", "package main\n\nimport \"corp/src/first/nested\"\n", true},
+		{"raw import", "corp/src/main", "package main\n\nimport `corp/src/second/nested`\n", true},
+		{"import of parent", "corp/src/main", "package main\n\nimport \"corp/src/second\"\n", false},
+		{"later match", "corp/src/main", "package main\n\nimport (\n\t\"corp/src/firstly\"\n\t\"corp/src/first\"\n)\n", true},
+		{"unquoted mention", "corp/src/main", "// Wraps corp/src/first\npackage main\n", false},
+		{"quoted at end of source", "corp/src/main", "package main\n\nvar s = \"corp/src/first", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.MayAffect(path.NewPath(tt.pkgPath), []byte(tt.src)))
+		})
+	}
+}
+
+// benchmarkFiles generates the sources of n files, one in every hundred of
+// which imports a moved package.
+func benchmarkFiles(n int) [][]byte {
+	files := make([][]byte, n)
+	for i := range files {
+		moved := "corp/src/unmoved"
+		if i%100 == 0 {
+			moved = "corp/src/first"
+		}
+
+		files[i] = []byte(fmt.Sprintf(`// Package pkg%d does things.
+package pkg%d
+
+import (
+	"fmt"
+	"strings"
+
+	"corp/src/common"
+	%q
+)
+
+// Describe describes the thing.
+func Describe(things []string) string {
+	var b strings.Builder
+	for i, thing := range things {
+		fmt.Fprintf(&b, "%%d: %%s\n", i, common.Clean(thing))
+	}
+
+	return b.String()
+}
+
+// Count counts the things.
+func Count(things []string) int {
+	n := 0
+	for _, thing := range things {
+		if thing != "" {
+			n++
+		}
+	}
+
+	return n
+}
+`, i, i, moved))
+	}
+
+	return files
+}
+
+var benchmarkPkgPath = path.NewPath("corp/src/main")
+
+func benchmarkMoves(b *testing.B) Moves {
+	moves, err := ParseMoves([]string{"corp/src/first:corp/src/other"})
+	require.NoError(b, err)
+	return moves
+}
+
+// BenchmarkAffected_ParseAll fully parses every file to find those affected.
+func BenchmarkAffected_ParseAll(b *testing.B) {
+	moves, files := benchmarkMoves(b), benchmarkFiles(1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, src := range files {
+			f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments|parser.AllErrors)
+			require.NoError(b, err)
+			moves.Affecting(benchmarkPkgPath, f)
+		}
+	}
+}
+
+// BenchmarkAffected_ImportsOnly parses the imports of every file, fully parsing
+// only those affected.
+func BenchmarkAffected_ImportsOnly(b *testing.B) {
+	moves, files := benchmarkMoves(b), benchmarkFiles(1000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, src := range files {
+			f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ImportsOnly)
+			require.NoError(b, err)
+			if len(moves.Affecting(benchmarkPkgPath, f)) == 0 {
+				continue
+			}
+
+			_, err = parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments|parser.AllErrors)
+			require.NoError(b, err)
+		}
+	}
+}
+
+// BenchmarkAffected_Prefilter scans every file, fully parsing only those that
+// may be affected.
+func BenchmarkAffected_Prefilter(b *testing.B) {
+	moves, files := benchmarkMoves(b), benchmarkFiles(1000)
+	p := moves.Prefilter()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, src := range files {
+			if !p.MayAffect(benchmarkPkgPath, src) {
+				continue
+			}
+
+			f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments|parser.AllErrors)
+			require.NoError(b, err)
+			moves.Affecting(benchmarkPkgPath, f)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package pkgs
+
+import (
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// VendorPkgPath returns the import path of the package vendored in the given
+// directory, or false if the directory is not within a vendor directory. The
+// directory is slash or OS separated.
+func VendorPkgPath(dir string) (path.Path, bool) {
+	elems := strings.FieldsFunc(dir, func(r rune) bool { return r == '/' || r == '\\' })
+	for i := len(elems) - 1; i >= 0; i-- {
+		if elems[i] == "vendor" {
+			return path.Path(elems[i+1:]), true
+		}
+	}
+
+	return nil, false
+}
+
+// MovedVendoredPkgs returns the packages listed in a vendor/modules.txt file
+// that are affected by the moves. The listing isn't rewritten to match, since
+// the go tool checks it against go.mod and the vendored directories; the
+// vendor directory must be regenerated with go mod vendor instead.
+func (moves Moves) MovedVendoredPkgs(contents []byte) []string {
+	var moved []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		// NB(mmihic): Each module is introduced by a "# module version" line,
+		// optionally followed by "## annotations", and then lists its packages
+		// one per line.
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if moves.BestMatch(path.NewPath(trimmed)) != nil {
+			moved = append(moved, trimmed)
+		}
+	}
+
+	return moved
+}
@@ -0,0 +1,54 @@
+package pkgs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestVendorPkgPath(t *testing.T) {
+	for _, tt := range []struct {
+		dir  string
+		want path.Path
+		ok   bool
+	}{
+		{"pkg/first", nil, false},
+		{"vendor", path.Path{}, true},
+		{"vendor/github.com/other/lib", path.NewPath("github.com/other/lib"), true},
+		{"./svc/vendor/github.com/other/lib/vendor/golang.org/x/sync", path.NewPath("golang.org/x/sync"), true},
+	} {
+		got, ok := VendorPkgPath(tt.dir)
+		assert.Equal(t, tt.ok, ok, tt.dir)
+		assert.Equal(t, tt.want, got, tt.dir)
+	}
+}
+
+func TestMoves_MovedVendoredPkgs(t *testing.T) {
+	moves, err := ParseMoves([]string{
+		"github.com/other/lib/old:github.com/other/lib/zz/new",
+		"github.com/other/lib/util:github.com/other/lib/internal",
+	})
+	require.NoError(t, err)
+
+	contents := `# github.com/other/lib v1.2.0
+## explicit
+github.com/other/lib
+github.com/other/lib/internal
+github.com/other/lib/old
+github.com/other/lib/old/nested
+github.com/other/lib/util
+# golang.org/x/sync v0.1.0
+## explicit; go 1.17
+golang.org/x/sync/errgroup
+`
+
+	assert.Equal(t, []string{
+		"github.com/other/lib/old",
+		"github.com/other/lib/old/nested",
+		"github.com/other/lib/util",
+	}, moves.MovedVendoredPkgs([]byte(contents)))
+	assert.Empty(t, Moves{}.MovedVendoredPkgs([]byte(contents)))
+}
@@ -0,0 +1,244 @@
+package rename
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// A conversion is a value of a concrete type used as a value of an interface
+// type, whether by assignment, by being passed or returned, or explicitly.
+type conversion struct {
+	unit *unit
+	pos  token.Pos
+	from types.Type
+
+	// to is the interface type as written, and iface its underlying interface
+	to    types.Type
+	iface *types.Interface
+}
+
+// conversions returns the conversions of concrete values to interfaces in
+// the unit's files.
+func (u *unit) conversions() []*conversion {
+	var convs []*conversion
+	add := func(expr ast.Expr, to types.Type) {
+		if expr == nil || to == nil {
+			return
+		}
+
+		iface, ok := to.Underlying().(*types.Interface)
+		if !ok {
+			return
+		}
+
+		tv, ok := u.info.Types[expr]
+		if !ok || tv.Type == nil || tv.IsNil() || types.IsInterface(tv.Type) {
+			return
+		}
+
+		if _, isTuple := tv.Type.(*types.Tuple); isTuple {
+			return
+		}
+
+		convs = append(convs, &conversion{unit: u, pos: expr.Pos(), from: tv.Type, to: to, iface: iface})
+	}
+
+	for _, f := range u.files {
+		u.inspectConversions(f, nil, add)
+	}
+
+	return convs
+}
+
+// inspectConversions finds the conversions to interfaces under the given
+// node, which is in the body of a function with the given signature, if any.
+func (u *unit) inspectConversions(root ast.Node, sig *types.Signature, add func(ast.Expr, types.Type)) {
+	ast.Inspect(root, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if fn, ok := u.info.Defs[n.Name].(*types.Func); ok && n.Body != nil {
+				u.inspectConversions(n.Body, fn.Type().(*types.Signature), add)
+			}
+			return false
+		case *ast.FuncLit:
+			if fnSig, ok := u.info.TypeOf(n).(*types.Signature); ok {
+				u.inspectConversions(n.Body, fnSig, add)
+			}
+			return false
+		case *ast.ReturnStmt:
+			if sig != nil && len(n.Results) == sig.Results().Len() {
+				for i, result := range n.Results {
+					add(result, sig.Results().At(i).Type())
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+				for i, lhs := range n.Lhs {
+					add(n.Rhs[i], u.info.TypeOf(lhs))
+				}
+			}
+		case *ast.ValueSpec:
+			if n.Type != nil {
+				for _, value := range n.Values {
+					add(value, u.info.TypeOf(n.Type))
+				}
+			}
+		case *ast.CallExpr:
+			u.callConversions(n, add)
+		case *ast.CompositeLit:
+			u.compositeLitConversions(n, add)
+		case *ast.SendStmt:
+			if ch, ok := underlying(u.info.TypeOf(n.Chan)).(*types.Chan); ok {
+				add(n.Value, ch.Elem())
+			}
+		case *ast.IndexExpr:
+			if m, ok := underlying(u.info.TypeOf(n.X)).(*types.Map); ok {
+				add(n.Index, m.Key())
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.EQL || n.Op == token.NEQ {
+				add(n.X, u.info.TypeOf(n.Y))
+				add(n.Y, u.info.TypeOf(n.X))
+			}
+		}
+		return true
+	})
+}
+
+// callConversions finds the conversions of the arguments of a call to the
+// types of the parameters, or of the operand of an explicit conversion.
+func (u *unit) callConversions(call *ast.CallExpr, add func(ast.Expr, types.Type)) {
+	tv, ok := u.info.Types[call.Fun]
+	if !ok {
+		return
+	}
+
+	if tv.IsType() {
+		if len(call.Args) == 1 {
+			add(call.Args[0], tv.Type)
+		}
+		return
+	}
+
+	sig, ok := underlying(tv.Type).(*types.Signature)
+	if !ok {
+		return
+	}
+
+	params := sig.Params()
+	for i, arg := range call.Args {
+		switch {
+		case sig.Variadic() && i >= params.Len()-1 && !call.Ellipsis.IsValid():
+			if slice, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok {
+				add(arg, slice.Elem())
+			}
+		case i < params.Len():
+			add(arg, params.At(i).Type())
+		}
+	}
+}
+
+// compositeLitConversions finds the conversions of the elements of a composite
+// literal to the types of the fields or elements.
+func (u *unit) compositeLitConversions(lit *ast.CompositeLit, add func(ast.Expr, types.Type)) {
+	switch t := underlying(u.info.TypeOf(lit)).(type) {
+	case *types.Struct:
+		for i, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				if i < t.NumFields() {
+					add(elt, t.Field(i).Type())
+				}
+				continue
+			}
+
+			if key, ok := kv.Key.(*ast.Ident); ok {
+				for j := 0; j < t.NumFields(); j++ {
+					if t.Field(j).Name() == key.Name {
+						add(kv.Value, t.Field(j).Type())
+					}
+				}
+			}
+		}
+	case *types.Slice:
+		addElts(lit, nil, t.Elem(), add)
+	case *types.Array:
+		addElts(lit, nil, t.Elem(), add)
+	case *types.Map:
+		addElts(lit, t.Key(), t.Elem(), add)
+	}
+}
+
+// addElts adds the conversions of the keys and values of the elements of a
+// slice, array or map literal.
+func addElts(lit *ast.CompositeLit, key, elem types.Type, add func(ast.Expr, types.Type)) {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			add(kv.Key, key)
+			add(kv.Value, elem)
+			continue
+		}
+
+		add(elt, elem)
+	}
+}
+
+// interfaces returns the interface types known to the unit: error, the
+// package level interfaces of its package and everything it imports, directly
+// or not, and the interface literals in its files. Any of these might be
+// asserted on a value once it has been converted to an interface.
+func (u *unit) interfaces() []types.Type {
+	var (
+		ifaces []types.Type
+		seen   = map[types.Type]bool{}
+	)
+
+	add := func(t types.Type) {
+		if _, ok := t.Underlying().(*types.Interface); ok && !seen[t] {
+			seen[t] = true
+			ifaces = append(ifaces, t)
+		}
+	}
+
+	add(types.Universe.Lookup("error").Type())
+
+	visited := map[*types.Package]bool{}
+	var visit func(pkg *types.Package)
+	visit = func(pkg *types.Package) {
+		if pkg == nil || visited[pkg] {
+			return
+		}
+		visited[pkg] = true
+
+		for _, name := range pkg.Scope().Names() {
+			if tn, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok {
+				add(tn.Type())
+			}
+		}
+
+		for _, imp := range pkg.Imports() {
+			visit(imp)
+		}
+	}
+	visit(u.types)
+
+	for _, tv := range u.info.Types {
+		if tv.Type != nil {
+			if _, isNamed := tv.Type.(*types.Named); !isNamed {
+				add(tv.Type)
+			}
+		}
+	}
+
+	return ifaces
+}
+
+// underlying returns the underlying type, or nil if the type is unknown.
+func underlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+
+	return t.Underlying()
+}
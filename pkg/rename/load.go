@@ -0,0 +1,245 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/filter"
+	"github.com/mmihic/go-tools/pkg/imports"
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// A Package is a package within the module.
+type Package struct {
+	Path       path.Path
+	Dir        string
+	Files      []*ast.File // files making up the package
+	TestFiles  []*ast.File // test files in the same package
+	XTestFiles []*ast.File // test files in the external _test package
+	Types      *types.Package
+
+	units []*unit
+}
+
+// A unit is a set of files that were type checked together.
+type unit struct {
+	pkg   *Package
+	files []*ast.File
+	types *types.Package
+	info  *types.Info
+}
+
+// A Module is the set of packages under a directory, parsed and type checked.
+type Module struct {
+	Fset     *token.FileSet
+	Packages map[string]*Package
+
+	external map[string]*types.Package
+	checking map[string]bool
+	imp      types.Importer
+}
+
+type importerFunc func(importPath string) (*types.Package, error)
+
+func (f importerFunc) Import(importPath string) (*types.Package, error) { return f(importPath) }
+
+// Load parses and type checks all of the packages under the given directory,
+// which is the root of the module with the given import path.
+func Load(dir, localPkgRoot string) (*Module, error) {
+	fset := token.NewFileSet()
+	m := &Module{
+		Fset:     fset,
+		Packages: map[string]*Package{},
+		external: map[string]*types.Package{},
+		checking: map[string]bool{},
+		imp:      importer.ForCompiler(fset, "source", nil),
+	}
+
+	if err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if p != dir && filter.IgnoredDir(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		pkgPath := path.NewPath(filepath.Join(localPkgRoot, rel))
+		return m.parseDir(p, pkgPath)
+	}); err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range m.sortedPackages() {
+		if _, err := m.importPkg(pkg.Path.String()); err != nil {
+			return nil, err
+		}
+
+		if len(pkg.TestFiles) != 0 {
+			files := append(append([]*ast.File{}, pkg.Files...), pkg.TestFiles...)
+			pkg.units = append(pkg.units, m.check(pkg, pkg.Path.String(), files))
+		}
+
+		if len(pkg.XTestFiles) != 0 {
+			pkg.units = append(pkg.units, m.check(pkg, pkg.Path.String()+"_test", pkg.XTestFiles))
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Module) parseDir(dir string, pkgPath path.Path) error {
+	matchFile := func(fi os.FileInfo) bool {
+		match, err := build.Default.MatchFile(dir, fi.Name())
+		return err == nil && match
+	}
+
+	pkgs, err := parser.ParseDir(m.Fset, dir, matchFile, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %v", dir, err)
+	}
+
+	if len(pkgs) == 0 {
+		return nil
+	}
+
+	pkg := &Package{
+		Path: pkgPath,
+		Dir:  dir,
+	}
+
+	var pkgName string
+	for name, astPkg := range pkgs {
+		for fname, f := range astPkg.Files {
+			switch {
+			case !strings.HasSuffix(fname, "_test.go"):
+				if pkgName != "" && pkgName != name {
+					return fmt.Errorf("found packages %s and %s in %s", pkgName, name, dir)
+				}
+				pkgName = name
+				pkg.Files = append(pkg.Files, f)
+			case strings.HasSuffix(name, "_test"):
+				pkg.XTestFiles = append(pkg.XTestFiles, f)
+			default:
+				pkg.TestFiles = append(pkg.TestFiles, f)
+			}
+		}
+	}
+
+	for _, files := range [][]*ast.File{pkg.Files, pkg.TestFiles, pkg.XTestFiles} {
+		m.sortFiles(files)
+	}
+
+	m.Packages[pkgPath.String()] = pkg
+	return nil
+}
+
+func (m *Module) sortFiles(files []*ast.File) {
+	sort.Slice(files, func(i, j int) bool {
+		return m.Filename(files[i]) < m.Filename(files[j])
+	})
+}
+
+// Filename returns the name of the file containing the given node.
+func (m *Module) Filename(n ast.Node) string {
+	return m.Fset.File(n.Pos()).Name()
+}
+
+func (m *Module) sortedPackages() []*Package {
+	pkgs := make([]*Package, 0, len(m.Packages))
+	for _, pkg := range m.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Path.String() < pkgs[j].Path.String()
+	})
+	return pkgs
+}
+
+// importPkg imports a package, type checking it from source if it belongs to
+// the module.
+func (m *Module) importPkg(importPath string) (*types.Package, error) {
+	pkg, ok := m.Packages[importPath]
+	if !ok {
+		return m.importExternal(importPath), nil
+	}
+
+	if pkg.Types != nil {
+		return pkg.Types, nil
+	}
+
+	if m.checking[importPath] {
+		return nil, fmt.Errorf("import cycle through %s", importPath)
+	}
+
+	m.checking[importPath] = true
+	defer delete(m.checking, importPath)
+
+	u := m.check(pkg, importPath, pkg.Files)
+	pkg.Types = u.types
+	pkg.units = append(pkg.units, u)
+	return pkg.Types, nil
+}
+
+// importExternal imports a package from outside of the module. Packages that
+// cannot be imported are replaced with an empty package, leaving references
+// into them unresolved.
+func (m *Module) importExternal(importPath string) *types.Package {
+	if pkg, ok := m.external[importPath]; ok {
+		return pkg
+	}
+
+	pkg, err := m.imp.Import(importPath)
+	if err != nil {
+		pkg = types.NewPackage(importPath, imports.PkgName(path.NewPath(importPath)))
+		pkg.MarkComplete()
+	}
+
+	m.external[importPath] = pkg
+	return pkg
+}
+
+func (m *Module) check(pkg *Package, pkgPath string, files []*ast.File) *unit {
+	info := &types.Info{
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Types:      map[ast.Expr]types.TypeAndValue{},
+	}
+
+	conf := types.Config{
+		Importer: importerFunc(m.importPkg),
+
+		// NB(mmihic): Type errors are expected, since we cannot always import
+		// packages from outside the module. Anything that cannot be resolved
+		// is reported by the rename itself if it matters.
+		Error: func(error) {},
+	}
+
+	tpkg, _ := conf.Check(pkgPath, m.Fset, files, info)
+	return &unit{
+		pkg:   pkg,
+		files: files,
+		types: tpkg,
+		info:  info,
+	}
+}
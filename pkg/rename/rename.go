@@ -0,0 +1,577 @@
+package rename
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/imports"
+	"github.com/mmihic/go-tools/pkg/scope"
+)
+
+// A ConflictError explains why a rename was refused.
+type ConflictError struct {
+	Target  *Target
+	NewName string
+	Reasons []string
+}
+
+// Error returns the error message.
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("cannot rename %s to %s:\n\t%s",
+		err.Target, err.NewName, strings.Join(err.Reasons, "\n\t"))
+}
+
+// Rename renames the target, and every reference to it within the module, to
+// the new name. Returns the files that were changed. The rename is refused,
+// leaving all files untouched, if it would conflict with an existing name,
+// change the meaning of any reference, or break interface satisfaction.
+func (m *Module) Rename(target *Target, newName string) ([]*ast.File, error) {
+	if !token.IsIdentifier(newName) || token.IsKeyword(newName) {
+		return nil, fmt.Errorf("%s is not a valid identifier", newName)
+	}
+
+	pkg, ok := m.Packages[target.Pkg.String()]
+	if !ok {
+		return nil, fmt.Errorf("package %s not found", target.Pkg)
+	}
+
+	obj, err := lookup(pkg, target)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renamer{
+		m:       m,
+		pkg:     pkg,
+		target:  target,
+		obj:     obj,
+		newName: newName,
+		refs:    map[*ast.Ident]*unit{},
+		reasons: map[string]struct{}{},
+	}
+
+	r.findRefs()
+	r.checkConflicts()
+	if len(r.reasons) != 0 {
+		reasons := make([]string, 0, len(r.reasons))
+		for reason := range r.reasons {
+			reasons = append(reasons, reason)
+		}
+
+		sort.Strings(reasons)
+		return nil, &ConflictError{
+			Target:  target,
+			NewName: newName,
+			Reasons: reasons,
+		}
+	}
+
+	changed := map[*ast.File]struct{}{}
+	for ref, u := range r.refs {
+		ref.Name = newName
+		changed[r.fileOf(u, ref)] = struct{}{}
+	}
+
+	files := make([]*ast.File, 0, len(changed))
+	for f := range changed {
+		files = append(files, f)
+	}
+
+	m.sortFiles(files)
+	return files, nil
+}
+
+// Resolve returns the reading of a target, as returned by ParseTargets, that
+// names a declaration in the module.
+func (m *Module) Resolve(targets []*Target) (*Target, error) {
+	var (
+		found []*Target
+		err   error
+	)
+
+	for _, target := range targets {
+		pkg, ok := m.Packages[target.Pkg.String()]
+		if !ok {
+			continue
+		}
+
+		if _, lookupErr := lookup(pkg, target); lookupErr != nil {
+			err = lookupErr
+			continue
+		}
+
+		found = append(found, target)
+	}
+
+	switch {
+	case len(found) == 1:
+		return found[0], nil
+	case len(found) > 1:
+		return nil, fmt.Errorf("%s is ambiguous, and could be %s in package %s or %s in package %s",
+			found[0], found[0].member(), found[0].Pkg, found[1].member(), found[1].Pkg)
+	case err != nil:
+		return nil, err
+	default:
+		return nil, fmt.Errorf("package %s not found", targets[0].Pkg)
+	}
+}
+
+// lookup finds the object named by the target.
+func lookup(pkg *Package, target *Target) (types.Object, error) {
+	obj := pkg.Types.Scope().Lookup(target.Name)
+	if target.Type != "" {
+		obj = pkg.Types.Scope().Lookup(target.Type)
+	}
+
+	if obj == nil {
+		return nil, fmt.Errorf("%s not found", target)
+	}
+
+	if target.Type == "" {
+		return obj, nil
+	}
+
+	typeName, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not a type", target.Pkg, target.Type)
+	}
+
+	member, _, _ := types.LookupFieldOrMethod(typeName.Type(), true, pkg.Types, target.Name)
+	if member == nil || !declaredOn(member, typeName.Type()) {
+		return nil, fmt.Errorf("%s not found", target)
+	}
+
+	if v, ok := member.(*types.Var); ok && v.Embedded() {
+		return nil, fmt.Errorf("%s is an embedded field; rename its type instead", target)
+	}
+
+	return member, nil
+}
+
+// declaredOn returns true if the field or method is declared directly on the
+// given type, rather than promoted from an embedded type.
+func declaredOn(member types.Object, typ types.Type) bool {
+	switch t := typ.Underlying().(type) {
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if t.Field(i) == member {
+				return true
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			if t.ExplicitMethod(i) == member {
+				return true
+			}
+		}
+	}
+
+	if named, ok := typ.(*types.Named); ok {
+		for i := 0; i < named.NumMethods(); i++ {
+			if named.Method(i) == member {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type renamer struct {
+	m       *Module
+	pkg     *Package
+	target  *Target
+	obj     types.Object
+	newName string
+	refs    map[*ast.Ident]*unit
+	reasons map[string]struct{}
+}
+
+func (r *renamer) conflict(pos token.Pos, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if pos.IsValid() {
+		msg = fmt.Sprintf("%s: %s", r.m.Fset.Position(pos), msg)
+	}
+	r.reasons[msg] = struct{}{}
+}
+
+// isTarget returns true if the object is the object being renamed. Packages
+// with tests are type checked more than once, so objects are matched by their
+// declaring position rather than by identity.
+func (r *renamer) isTarget(obj types.Object) bool {
+	return obj != nil && obj.Pos() == r.obj.Pos() && obj.Name() == r.obj.Name()
+}
+
+func (r *renamer) units() []*unit {
+	var units []*unit
+	for _, pkg := range r.m.sortedPackages() {
+		units = append(units, pkg.units...)
+	}
+	return units
+}
+
+func (r *renamer) findRefs() {
+	for _, u := range r.units() {
+		for _, idents := range []map[*ast.Ident]types.Object{u.info.Defs, u.info.Uses} {
+			for ident, obj := range idents {
+				if r.isTarget(obj) {
+					r.refs[ident] = u
+				}
+			}
+		}
+	}
+}
+
+func (r *renamer) fileOf(u *unit, n ast.Node) *ast.File {
+	for _, f := range u.files {
+		if f.Pos() <= n.Pos() && n.Pos() < f.End() {
+			return f
+		}
+	}
+	return nil
+}
+
+func (r *renamer) checkConflicts() {
+	if r.target.Type == "" {
+		r.checkPackageConflicts()
+	} else {
+		r.checkMemberConflicts()
+	}
+
+	if ast.IsExported(r.obj.Name()) && !ast.IsExported(r.newName) {
+		for ref, u := range r.refs {
+			if u.pkg != r.pkg || u.types.Name() != r.pkg.Types.Name() {
+				r.conflict(ref.Pos(), "%s would no longer be accessible here", r.newName)
+			}
+		}
+	}
+
+	r.checkShadowing()
+}
+
+// checkPackageConflicts checks whether the new name is already declared in the
+// package, or in the file scope of any of the package's files.
+func (r *renamer) checkPackageConflicts() {
+	for _, u := range r.pkg.units {
+		if u.types.Name() != r.pkg.Types.Name() {
+			continue
+		}
+
+		if existing := u.types.Scope().Lookup(r.newName); existing != nil {
+			r.conflict(existing.Pos(), "%s is already declared in %s", r.newName, r.target.Pkg)
+		}
+
+		for _, f := range u.files {
+			for _, imp := range f.Imports {
+				if imports.Name(imp) == r.newName {
+					r.conflict(imp.Pos(), "%s conflicts with import %s", r.newName, imp.Path.Value)
+				}
+			}
+		}
+	}
+}
+
+// checkMemberConflicts checks whether the new name conflicts with an existing
+// field or method of the type, and whether the rename would break interface
+// satisfaction anywhere in the module.
+func (r *renamer) checkMemberConflicts() {
+	typeName := r.pkg.Types.Scope().Lookup(r.target.Type)
+	typ := typeName.Type()
+	if existing, _, _ := types.LookupFieldOrMethod(typ, true, r.pkg.Types, r.newName); existing != nil {
+		if declaredOn(existing, typ) {
+			r.conflict(existing.Pos(), "%s.%s already exists", r.target.Type, r.newName)
+		} else {
+			r.conflict(existing.Pos(), "%s.%s would hide promoted %s", r.target.Type, r.newName, existing)
+		}
+	}
+
+	r.checkEmbedders(typeName)
+
+	if _, isMethod := r.obj.(*types.Func); isMethod {
+		r.checkInterfaces(typeName, typ)
+	}
+
+	// Without type information we can't tell whether a selector refers to the
+	// target, so we refuse rather than risk leaving a reference behind.
+	for _, u := range r.units() {
+		for _, f := range u.files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				sel, ok := n.(*ast.SelectorExpr)
+				if !ok || sel.Sel.Name != r.obj.Name() {
+					return true
+				}
+
+				if _, ok := u.info.Selections[sel]; ok {
+					return true
+				}
+
+				if _, ok := u.info.Uses[sel.Sel]; ok {
+					return true
+				}
+
+				r.conflict(sel.Pos(), "unable to resolve the type of %s", exprString(sel))
+				return true
+			})
+		}
+	}
+}
+
+// checkEmbedders checks that no type embedding the target's type, directly or
+// transitively, already has a field or method with the new name. The renamed
+// member would be promoted alongside it, changing which of the two selectors
+// on the embedding type refer to.
+func (r *renamer) checkEmbedders(typeName types.Object) {
+	name := r.obj.Name()
+	for _, t := range r.allTypes() {
+		if r.isTargetType(typeName, t.typ) {
+			continue
+		}
+
+		promoted, _, _ := types.LookupFieldOrMethod(t.typ, true, t.pkg, name)
+		if !r.isTarget(promoted) {
+			continue
+		}
+
+		if existing, _, _ := types.LookupFieldOrMethod(t.typ, true, t.pkg, r.newName); existing != nil {
+			r.conflict(existing.Pos(), "%s embeds %s, and already has %s, so renaming would change what %s refers to",
+				t.typ, r.target.Type, r.newName, r.newName)
+		}
+	}
+}
+
+// A typeInUnit is a type declared or used in a unit, with the package from
+// which its unexported members are accessible.
+type typeInUnit struct {
+	typ types.Type
+	pkg *types.Package
+}
+
+// allTypes returns every named type declared in the module, at package level
+// or not, along with every struct literal type used in it.
+func (r *renamer) allTypes() []typeInUnit {
+	var (
+		all  []typeInUnit
+		seen = map[types.Type]bool{}
+	)
+
+	for _, u := range r.units() {
+		for _, obj := range u.info.Defs {
+			tn, ok := obj.(*types.TypeName)
+			if !ok || seen[tn.Type()] {
+				continue
+			}
+
+			if _, isTypeParam := tn.Type().(*types.TypeParam); !isTypeParam {
+				seen[tn.Type()] = true
+				all = append(all, typeInUnit{typ: tn.Type(), pkg: tn.Pkg()})
+			}
+		}
+
+		for _, tv := range u.info.Types {
+			if _, isStruct := tv.Type.(*types.Struct); isStruct && !seen[tv.Type] {
+				seen[tv.Type] = true
+				all = append(all, typeInUnit{typ: tv.Type, pkg: u.types})
+			}
+		}
+	}
+
+	return all
+}
+
+// isTargetType returns true if the type, or the type it points to, is the
+// type whose member is being renamed. As with isTarget, types are matched by
+// their declaring position since packages may be type checked more than once.
+func (r *renamer) isTargetType(typeName types.Object, typ types.Type) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+	return ok && named.Obj().Pos() == typeName.Pos() && named.Obj().Name() == typeName.Name()
+}
+
+// checkInterfaces checks that renaming a method does not change which
+// interfaces are implemented by which types, among the types declared in the
+// module and the values converted to interfaces anywhere in it.
+func (r *renamer) checkInterfaces(typeName types.Object, typ types.Type) {
+	name := r.obj.Name()
+	_, renamingInterface := typ.Underlying().(*types.Interface)
+	for _, other := range r.namedTypes() {
+		otherType := other.Type()
+		_, otherIsInterface := otherType.Underlying().(*types.Interface)
+		if renamingInterface == otherIsInterface {
+			continue
+		}
+
+		if m, _, _ := types.LookupFieldOrMethod(otherType, true, other.Pkg(), name); m == nil {
+			continue
+		}
+
+		iface, concrete := otherType, typ
+		if renamingInterface {
+			iface, concrete = typ, otherType
+		}
+
+		if implements(concrete, iface.Underlying().(*types.Interface)) {
+			r.conflict(other.Pos(),
+				"renaming would break the implementation of %s by %s", iface, concrete)
+		}
+	}
+
+	for _, u := range r.units() {
+		var known []types.Type
+		for _, conv := range u.conversions() {
+			switch {
+			case renamingInterface:
+				if r.isTargetType(typeName, conv.to) && implements(conv.from, conv.iface) {
+					r.conflict(conv.pos, "renaming would break the conversion of %s to %s", conv.from, conv.to)
+				}
+			case !r.isTargetType(typeName, conv.from):
+			case hasMethod(conv.iface, name):
+				if implements(conv.from, conv.iface) {
+					r.conflict(conv.pos, "renaming would break the conversion of %s to %s", conv.from, conv.to)
+				}
+			default:
+				// NB(mmihic): Once converted to an interface, the value may be
+				// asserted to any interface it implements, such as fmt.Stringer
+				// by the fmt package, and the rename would silently change the
+				// outcome.
+				if known == nil {
+					known = u.interfaces()
+				}
+
+				for _, other := range known {
+					if iface := other.Underlying().(*types.Interface); hasMethod(iface, name) && implements(conv.from, iface) {
+						r.conflict(conv.pos, "%s is used as an interface value, and renaming would stop it implementing %s",
+							conv.from, other)
+					}
+				}
+			}
+		}
+	}
+}
+
+// hasMethod returns true if the interface has a method with the given name.
+func hasMethod(iface *types.Interface, name string) bool {
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func implements(typ types.Type, iface *types.Interface) bool {
+	if types.Implements(typ, iface) {
+		return true
+	}
+
+	if _, isPtr := typ.(*types.Pointer); !isPtr {
+		return types.Implements(types.NewPointer(typ), iface)
+	}
+	return false
+}
+
+// namedTypes returns all of the package level named types in the module.
+func (r *renamer) namedTypes() []*types.TypeName {
+	var named []*types.TypeName
+	for _, pkg := range r.m.sortedPackages() {
+		if pkg.Types == nil {
+			continue
+		}
+
+		s := pkg.Types.Scope()
+		for _, name := range s.Names() {
+			if tn, ok := s.Lookup(name).(*types.TypeName); ok {
+				named = append(named, tn)
+			}
+		}
+	}
+	return named
+}
+
+// checkShadowing checks that, at each unqualified reference to the target,
+// the new name is not already bound to some other declaration.
+func (r *renamer) checkShadowing() {
+	if r.target.Type != "" {
+		return
+	}
+
+	seen := map[*ast.File]struct{}{}
+	for ref, u := range r.refs {
+		f := r.fileOf(u, ref)
+		if _, ok := seen[f]; ok {
+			continue
+		}
+		seen[f] = struct{}{}
+
+		// References through a dot import are unqualified, and so can also
+		// conflict with the importing package's own declarations.
+		dotImported := u.pkg != r.pkg || u.types.Name() != r.pkg.Types.Name()
+		if existing := u.types.Scope().Lookup(r.newName); dotImported && existing != nil {
+			r.conflict(existing.Pos(), "%s is already declared in %s", r.newName, u.types.Path())
+		}
+
+		qualified := map[*ast.Ident]struct{}{}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				qualified[sel.Sel] = struct{}{}
+			}
+			return true
+		})
+
+		scope.Inspect(f, func(n ast.Node, s *scope.Scope) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+
+			if _, isRef := r.refs[ident]; !isRef {
+				return true
+			}
+
+			if _, isQualified := qualified[ident]; isQualified {
+				return true
+			}
+
+			if decl := s.GetDecl(r.newName); decl != nil && !isPackageLevel(f, decl) {
+				r.conflict(ident.Pos(), "%s would be shadowed by the declaration at %s",
+					r.newName, r.m.Fset.Position(decl.Pos()))
+			}
+			return true
+		})
+	}
+}
+
+// isPackageLevel returns true if the declaration is a package level
+// declaration, which has already been checked for conflicts.
+func isPackageLevel(f *ast.File, decl ast.Node) bool {
+	for _, d := range f.Decls {
+		if d == decl {
+			return true
+		}
+
+		if gen, ok := d.(*ast.GenDecl); ok && gen.Tok != token.IMPORT {
+			for _, spec := range gen.Specs {
+				if spec == decl {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func exprString(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return exprString(e.X) + "." + e.Sel.Name
+	}
+	return "(...)"
+}
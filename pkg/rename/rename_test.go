@@ -0,0 +1,409 @@
+package rename
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mmihic/go-tools/pkg/astio"
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets("pkg/path.OldName")
+	require.NoError(t, err)
+	assert.Equal(t, []*Target{{Pkg: path.NewPath("pkg/path"), Name: "OldName"}}, targets)
+
+	targets, err = ParseTargets("pkg/path.Type.Method")
+	require.NoError(t, err)
+	assert.Equal(t, []*Target{
+		{Pkg: path.NewPath("pkg/path.Type"), Name: "Method"},
+		{Pkg: path.NewPath("pkg/path"), Type: "Type", Name: "Method"},
+	}, targets)
+
+	// NB(mmihic): Dots in the last element of the package path are part of
+	// the package path, not a type.
+	targets, err = ParseTargets("gopkg.in/yaml.v2.Marshal")
+	require.NoError(t, err)
+	assert.Equal(t, []*Target{
+		{Pkg: path.NewPath("gopkg.in/yaml.v2"), Name: "Marshal"},
+		{Pkg: path.NewPath("gopkg.in/yaml"), Type: "v2", Name: "Marshal"},
+	}, targets)
+
+	targets, err = ParseTargets("gopkg.in/yaml.v2.Decoder.Decode")
+	require.NoError(t, err)
+	assert.Equal(t, &Target{Pkg: path.NewPath("gopkg.in/yaml.v2"), Type: "Decoder", Name: "Decode"}, targets[1])
+
+	for _, invalid := range []string{"pkg/path", "pkg/path.", "pkg/path..A", "pkg/path.1abc"} {
+		_, err = ParseTargets(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+var testModule = map[string]string{
+	"first/first.go": `
+package first
+
+import "fmt"
+
+// Greeting is a greeting.
+type Greeting struct {
+	Name string
+}
+
+// Greeter greets.
+type Greeter interface {
+	Greet() string
+}
+
+// Greet returns the greeting.
+func (g *Greeting) Greet() string { return fmt.Sprintf("hello %s", g.Name) }
+
+// Format formats the greeting.
+func (g *Greeting) Format() string { return Old(g.Name) }
+
+// Old is the function being renamed.
+func Old(s string) string { return s }
+`,
+	"first/first_test.go": `
+package first
+
+func testOld() string { return Old("x") }
+`,
+	"second/second.go": `
+package second
+
+import (
+	"example.com/mod/first"
+)
+
+func Do() string {
+	var New = "shadows nothing, since Old is qualified"
+	g := &first.Greeting{Name: New}
+	return first.Old(g.Name) + g.Format()
+}
+`,
+}
+
+func writeModule(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "rename")
+	require.NoError(t, err)
+
+	for fname, contents := range files {
+		fname = filepath.Join(dir, fname)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fname), 0755))
+		require.NoError(t, ioutil.WriteFile(fname, []byte(strings.TrimLeft(contents, "\n")), 0644))
+	}
+
+	return dir
+}
+
+func rename(t *testing.T, files map[string]string, target, newName string) (map[string]string, error) {
+	dir := writeModule(t, files)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	m, err := Load(dir, "example.com/mod")
+	require.NoError(t, err)
+
+	targets, err := ParseTargets(target)
+	require.NoError(t, err)
+
+	for i, tgt := range targets {
+		targets[i] = tgt.ApplyPrefix(path.NewPath("example.com/mod"))
+	}
+
+	tgt, err := m.Resolve(targets)
+	if err != nil {
+		return nil, err
+	}
+
+	changed, err := m.Rename(tgt, newName)
+	if err != nil {
+		return nil, err
+	}
+
+	results := map[string]string{}
+	for _, f := range changed {
+		fname, err := filepath.Rel(dir, m.Filename(f))
+		require.NoError(t, err)
+
+		results[fname], err = astio.String(m.Fset, f)
+		require.NoError(t, err)
+	}
+
+	return results, nil
+}
+
+func TestRename(t *testing.T) {
+	results, err := rename(t, testModule, "first.Old", "New")
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Contains(t, results["first/first.go"], "func New(s string) string { return s }")
+	assert.Contains(t, results["first/first.go"], "return New(g.Name)")
+	assert.Contains(t, results["first/first_test.go"], `return New("x")`)
+	assert.Contains(t, results["second/second.go"], "return first.New(g.Name) + g.Format()")
+}
+
+func TestRename_DottedPackagePath(t *testing.T) {
+	files := map[string]string{
+		"yaml.v2/yaml.go": `
+package yaml
+
+// Marshal marshals.
+func Marshal(v interface{}) ([]byte, error) { return nil, nil }
+`,
+		"user/user.go": `
+package user
+
+import "example.com/mod/yaml.v2"
+
+var _, _ = yaml.Marshal(nil)
+`,
+	}
+
+	results, err := rename(t, files, "yaml.v2.Marshal", "Encode")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Contains(t, results["yaml.v2/yaml.go"], "func Encode(v interface{})")
+	assert.Contains(t, results["user/user.go"], "yaml.Encode(nil)")
+
+	_, err = rename(t, files, "yaml.v2.Missing", "Encode")
+	assert.EqualError(t, err, "example.com/mod/yaml.v2.Missing not found")
+}
+
+func TestRename_Members(t *testing.T) {
+	results, err := rename(t, testModule, "first.Greeting.Name", "Who")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Contains(t, results["first/first.go"], "Who string")
+	assert.Contains(t, results["first/first.go"], `fmt.Sprintf("hello %s", g.Who)`)
+	assert.Contains(t, results["second/second.go"], "&first.Greeting{Who: New}")
+	assert.Contains(t, results["second/second.go"], "first.Old(g.Who)")
+
+	results, err = rename(t, testModule, "first.Greeting.Format", "String")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Contains(t, results["first/first.go"], "func (g *Greeting) String() string")
+	assert.Contains(t, results["second/second.go"], "g.String()")
+}
+
+func TestRename_InterfaceValues(t *testing.T) {
+	files := map[string]string{
+		"first/first.go": `
+package first
+
+import "fmt"
+
+type P struct{}
+
+func (P) Unique() {}
+
+func show() { fmt.Println(P{}) }
+`,
+	}
+
+	// No interface has the method, so values used as interfaces are unaffected
+	results, err := rename(t, files, "first.P.Unique", "Special")
+	require.NoError(t, err)
+	assert.Contains(t, results["first/first.go"], "func (P) Special() {}")
+}
+
+func TestRename_Conflicts(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		target  string
+		newName string
+		files   map[string]string
+		reason  string
+	}{
+		{
+			name:    "already declared in package",
+			target:  "first.Old",
+			newName: "Greeting",
+			reason:  "Greeting is already declared in example.com/mod/first",
+		},
+		{
+			name:    "shadowed at a reference",
+			target:  "first.Old",
+			newName: "s",
+			files: map[string]string{
+				"first/shadow.go": `
+package first
+
+func shadow(s string) string { return Old(s) }
+`,
+			},
+			reason: "s would be shadowed by the declaration",
+		},
+		{
+			name:    "existing method",
+			target:  "first.Greeting.Format",
+			newName: "Greet",
+			reason:  "Greeting.Greet already exists",
+		},
+		{
+			name:    "breaks interface satisfaction",
+			target:  "first.Greeting.Greet",
+			newName: "Hello",
+			reason:  "renaming would break the implementation of example.com/mod/first.Greeter by example.com/mod/first.Greeting",
+		},
+		{
+			name:    "interface method with implementations",
+			target:  "first.Greeter.Greet",
+			newName: "Hello",
+			reason:  "renaming would break the implementation of example.com/mod/first.Greeter by example.com/mod/first.Greeting",
+		},
+		{
+			name:    "breaks a conversion to error",
+			target:  "first.E.Error",
+			newName: "Message",
+			files: map[string]string{
+				"first/err.go": `
+package first
+
+type E struct{}
+
+func (E) Error() string { return "failed" }
+
+func fail() error { return E{} }
+`,
+			},
+			reason: "renaming would break the conversion of example.com/mod/first.E to error",
+		},
+		{
+			name:    "breaks an interface literal",
+			target:  "first.E.Name",
+			newName: "Label",
+			files: map[string]string{
+				"first/named.go": `
+package first
+
+type E struct{}
+
+func (E) Name() string { return "e" }
+
+var _ interface{ Name() string } = E{}
+`,
+			},
+			reason: "renaming would break the conversion of example.com/mod/first.E to interface{Name() string}",
+		},
+		{
+			name:    "breaks a dynamic fmt.Stringer",
+			target:  "first.S.String",
+			newName: "Describe",
+			files: map[string]string{
+				"first/stringer.go": `
+package first
+
+import "fmt"
+
+type S struct{}
+
+func (*S) String() string { return "s" }
+
+func show() { fmt.Println(&S{}) }
+`,
+			},
+			reason: "*example.com/mod/first.S is used as an interface value, and renaming would stop it implementing fmt.Stringer",
+		},
+		{
+			name:    "breaks a conversion of an external type",
+			target:  "first.Writer.Write",
+			newName: "Put",
+			files: map[string]string{
+				"first/writer.go": `
+package first
+
+import "strings"
+
+type Writer interface {
+	Write(p []byte) (int, error)
+}
+
+func use(w Writer) {}
+
+func build() { use(&strings.Builder{}) }
+`,
+			},
+			reason: "renaming would break the conversion of *strings.Builder to example.com/mod/first.Writer",
+		},
+		{
+			name:    "hidden by a method of an embedding type",
+			target:  "first.T.Old",
+			newName: "New",
+			files: map[string]string{
+				"first/embed.go": `
+package first
+
+type T struct{}
+
+func (T) Old() {}
+
+type S struct{ T }
+
+func (S) New() {}
+
+func use(s S) { s.Old() }
+`,
+			},
+			reason: "example.com/mod/first.S embeds T, and already has New",
+		},
+		{
+			name:    "hidden by a field of a transitively embedding type",
+			target:  "first.T.Old",
+			newName: "New",
+			files: map[string]string{
+				"first/embed.go": `
+package first
+
+type T struct{}
+
+func (T) Old() {}
+
+type S struct{ *T }
+`,
+				"second/embed.go": `
+package second
+
+import "example.com/mod/first"
+
+type U struct {
+	first.S
+	New int
+}
+`,
+			},
+			reason: "example.com/mod/second.U embeds T, and already has New",
+		},
+		{
+			name:    "exported name used by other packages",
+			target:  "first.Old",
+			newName: "old",
+			reason:  "old would no longer be accessible here",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			for fname, contents := range testModule {
+				files[fname] = contents
+			}
+			for fname, contents := range tt.files {
+				files[fname] = contents
+			}
+
+			_, err := rename(t, files, tt.target, tt.newName)
+			if assert.Error(t, err) {
+				assert.IsType(t, &ConflictError{}, err)
+				assert.Contains(t, err.Error(), tt.reason)
+			}
+		})
+	}
+}
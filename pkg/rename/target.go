@@ -0,0 +1,89 @@
+// Package rename renames exported identifiers everywhere they are referenced
+// within a module.
+package rename
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/mmihic/go-tools/pkg/path"
+)
+
+// A Target identifies a package level declaration, or a method or field of a
+// package level type.
+type Target struct {
+	Pkg  path.Path
+	Type string
+	Name string
+}
+
+// ParseTargets parses a target of the form pkg/path.Name or
+// pkg/path.Type.Name. The last element of a package path may itself contain
+// dots, as in gopkg.in/yaml.v2, so a target can be read more than one way;
+// every reading is returned, and Module.Resolve picks the one that names a
+// declaration.
+func ParseTargets(s string) ([]*Target, error) {
+	dir, base := "", s
+	if n := strings.LastIndex(s, "/"); n >= 0 {
+		dir, base = s[:n+1], s[n+1:]
+	}
+
+	parts := strings.Split(base, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid rename target %s", s)
+	}
+
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid rename target %s", s)
+		}
+	}
+
+	name := parts[len(parts)-1]
+	if !token.IsIdentifier(name) {
+		return nil, fmt.Errorf("invalid rename target %s: %s is not an identifier", s, name)
+	}
+
+	targets := []*Target{{
+		Pkg:  path.NewPath(dir + strings.Join(parts[:len(parts)-1], ".")),
+		Name: name,
+	}}
+
+	if typ := parts[len(parts)-2]; len(parts) > 2 && token.IsIdentifier(typ) {
+		targets = append(targets, &Target{
+			Pkg:  path.NewPath(dir + strings.Join(parts[:len(parts)-2], ".")),
+			Type: typ,
+			Name: name,
+		})
+	}
+
+	return targets, nil
+}
+
+// ApplyPrefix applies a prefix to the target's package path.
+func (t *Target) ApplyPrefix(prefix path.Path) *Target {
+	return &Target{
+		Pkg:  prefix.Append(t.Pkg),
+		Type: t.Type,
+		Name: t.Name,
+	}
+}
+
+// member returns the target without its package, as Name or Type.Name.
+func (t *Target) member() string {
+	if t.Type != "" {
+		return t.Type + "." + t.Name
+	}
+
+	return t.Name
+}
+
+// String returns the string form of the target.
+func (t *Target) String() string {
+	if t.Type != "" {
+		return fmt.Sprintf("%s.%s.%s", t.Pkg, t.Type, t.Name)
+	}
+
+	return fmt.Sprintf("%s.%s", t.Pkg, t.Name)
+}
@@ -0,0 +1,239 @@
+// Package report collects a machine-readable report of the changes made by
+// applying a set of moves.
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"go.uber.org/multierr"
+
+	"github.com/mmihic/go-tools/pkg/pkgs"
+)
+
+// Kinds of files reported.
+const (
+	KindGo  = "go"
+	KindAsm = "asm"
+)
+
+// Reasons for skipping a file or directory.
+const (
+	SkippedByGo        = "ignored by the go tool"
+	SkippedByGit       = "ignored by git"
+	SkippedByFilter    = "excluded by filter"
+	SkippedByPlatforms = "not built for the selected platforms"
+	SkippedGenerated   = "generated"
+
+	SkippedVendorModules = "lists moved vendored packages, regenerate with go mod vendor"
+
+	SkippedSyntaxErrors = "syntax errors in a file unaffected by the moves"
+)
+
+// A Report describes the changes made by applying a set of moves. It is safe
+// for concurrent use while being built.
+type Report struct {
+	mu sync.Mutex
+
+	Files     []*File      `json:"files"`
+	Skipped   []*Skipped   `json:"skipped"`
+	Generated []*Generated `json:"generated"`
+	Errors    []*Error     `json:"errors,omitempty"`
+	Stats     Stats        `json:"stats"`
+}
+
+// A File is a file changed by the moves.
+type File struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+
+	// BuildConstraints are the file's build constraints, if any.
+	BuildConstraints string `json:"build_constraints,omitempty"`
+
+	// Generated is the policy applied to the file, if it is generated.
+	Generated pkgs.GeneratedPolicy `json:"generated,omitempty"`
+
+	*pkgs.FileChanges
+
+	// ImportsCleanedUp is true if unused, duplicate or redundantly aliased
+	// imports were removed after applying the moves.
+	ImportsCleanedUp bool `json:"imports_cleaned_up,omitempty"`
+
+	// Written is true if the changes were written.
+	Written bool `json:"written"`
+}
+
+// Skipped is a file or directory that was skipped.
+type Skipped struct {
+	Path   string `json:"path"`
+	Dir    bool   `json:"dir,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// A Generated is a generated file affected by the moves, whether or not it
+// ended up changed.
+type Generated struct {
+	Path   string               `json:"path"`
+	Policy pkgs.GeneratedPolicy `json:"policy"`
+
+	// Directives are the go:generate directives run to regenerate the file,
+	// if its policy is to regenerate it.
+	Directives []string `json:"directives,omitempty"`
+
+	// Error is the error regenerating the file, which was left as rewritten.
+	Error string `json:"error,omitempty"`
+}
+
+// An Error is an error encountered while applying the moves. The position and
+// move are only known for errors rewriting a file.
+type Error struct {
+	Path   string `json:"path,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Move   string `json:"move,omitempty"`
+	Error  string `json:"error"`
+}
+
+// newError creates the report of the given error.
+func newError(err error) *Error {
+	rwErr, ok := err.(*pkgs.RewriteError)
+	if !ok {
+		return &Error{Error: err.Error()}
+	}
+
+	e := &Error{
+		Path:   rwErr.Filename,
+		Line:   rwErr.Pos.Line,
+		Column: rwErr.Pos.Column,
+		Error:  rwErr.Cause.Error(),
+	}
+
+	if rwErr.Move != nil {
+		e.Move = rwErr.Move.From.String() + " -> " + rwErr.Move.To.String()
+	}
+
+	return e
+}
+
+// Stats are aggregate statistics over the whole report.
+type Stats struct {
+	FilesProcessed     int `json:"files_processed"`
+	FilesChanged       int `json:"files_changed"`
+	FilesWritten       int `json:"files_written"`
+	FilesSkipped       int `json:"files_skipped"`
+	DirsSkipped        int `json:"dirs_skipped"`
+	ImportsRewritten   int `json:"imports_rewritten"`
+	PackagesRenamed    int `json:"packages_renamed"`
+	SelfImportsRemoved int `json:"self_imports_removed"`
+	GeneratedFiles     int `json:"generated_files"`
+	Errors             int `json:"errors"`
+}
+
+// New creates a new, empty, Report.
+func New() *Report {
+	return &Report{
+		Files:     []*File{},
+		Skipped:   []*Skipped{},
+		Generated: []*Generated{},
+	}
+}
+
+// Processed records that a file was processed, whether or not it changed.
+func (r *Report) Processed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Stats.FilesProcessed++
+}
+
+// Changed records a file changed by the moves.
+func (r *Report) Changed(f *File) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Files = append(r.Files, f)
+	r.Stats.FilesChanged++
+
+	if f.FileChanges == nil {
+		return
+	}
+
+	r.Stats.ImportsRewritten += len(f.Imports)
+	r.Stats.SelfImportsRemoved += len(f.SelfImportsRemoved)
+	if f.Package != nil {
+		r.Stats.PackagesRenamed++
+	}
+}
+
+// Skip records a file or directory that was skipped.
+func (r *Report) Skip(path string, dir bool, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Skipped = append(r.Skipped, &Skipped{Path: path, Dir: dir, Reason: reason})
+	if dir {
+		r.Stats.DirsSkipped++
+	} else {
+		r.Stats.FilesSkipped++
+	}
+}
+
+// Affected records a generated file affected by the moves.
+func (r *Report) Affected(g *Generated) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Generated = append(r.Generated, g)
+	r.Stats.GeneratedFiles++
+}
+
+// RegenerateFailed records the error regenerating a generated file.
+func (r *Report) RegenerateFailed(g *Generated, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g.Error = err.Error()
+}
+
+// Finish completes the report, given the names of the files that were written
+// and the errors encountered.
+func (r *Report) Finish(written []string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wasWritten := map[string]bool{}
+	for _, fname := range written {
+		wasWritten[fname] = true
+	}
+
+	r.Stats.FilesWritten = 0
+	for _, f := range r.Files {
+		f.Written = wasWritten[f.Path]
+		if f.Written {
+			r.Stats.FilesWritten++
+		}
+	}
+
+	r.Errors = nil
+	for _, err := range multierr.Errors(err) {
+		r.Errors = append(r.Errors, newError(err))
+	}
+	r.Stats.Errors = len(r.Errors)
+
+	sort.Slice(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+	sort.Slice(r.Skipped, func(i, j int) bool { return r.Skipped[i].Path < r.Skipped[j].Path })
+	sort.Slice(r.Generated, func(i, j int) bool { return r.Generated[i].Path < r.Generated[j].Path })
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
@@ -0,0 +1,167 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+
+	"github.com/mmihic/go-tools/pkg/pkgs"
+)
+
+func TestReport(t *testing.T) {
+	r := New()
+	for i := 0; i < 4; i++ {
+		r.Processed()
+	}
+
+	r.Changed(&File{
+		Path: "pkg/b/b.go",
+		Kind: KindGo,
+		FileChanges: &pkgs.FileChanges{
+			Imports: []*pkgs.ImportChange{
+				{OldPath: "example.com/pkg/old", NewPath: "example.com/pkg/b"},
+				{OldPath: "example.com/pkg/other", NewPath: "example.com/pkg/new", NewAlias: "new2"},
+			},
+			Package:            &pkgs.PackageChange{OldName: "old", NewName: "b"},
+			SelfImportsRemoved: []string{"example.com/pkg/b"},
+		},
+		ImportsCleanedUp: true,
+	})
+	r.Changed(&File{Path: "pkg/a/a_amd64.s", Kind: KindAsm, BuildConstraints: "amd64"})
+	r.Changed(&File{Path: "pkg/a/a.pb.go", Kind: KindGo, Generated: pkgs.GeneratedRegenerate})
+	r.Affected(&Generated{
+		Path:       "pkg/a/a.pb.go",
+		Policy:     pkgs.GeneratedRegenerate,
+		Directives: []string{"pkg/a/gen.go:3: //go:generate protoc --go_out=. a.proto"},
+	})
+	r.Affected(&Generated{Path: "pkg/a/a_string.go", Policy: pkgs.GeneratedSkip})
+	r.Skip("pkg/a/a_string.go", false, SkippedGenerated)
+	r.Skip("pkg/testdata", true, SkippedByGo)
+	r.Skip("pkg/a/a_windows.go", false, SkippedByPlatforms)
+
+	mv, err := pkgs.ParseMove("pkg/old:pkg/b")
+	require.NoError(t, err)
+
+	r.Finish([]string{"pkg/a/a_amd64.s", "pkg/b/b.go"}, multierr.Combine(
+		&pkgs.RewriteError{
+			Filename: "pkg/c/c.go",
+			Pos:      token.Position{Filename: "pkg/c/c.go", Line: 3, Column: 8},
+			Move:     mv,
+			Cause:    errors.New("syntax error: expected ';', found 'EOF'"),
+		},
+		errors.New("unable to write journal"),
+	))
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteJSON(&buf))
+	assert.Equal(t, `{
+  "files": [
+    {
+      "path": "pkg/a/a.pb.go",
+      "kind": "go",
+      "generated": "generate",
+      "written": false
+    },
+    {
+      "path": "pkg/a/a_amd64.s",
+      "kind": "asm",
+      "build_constraints": "amd64",
+      "written": true
+    },
+    {
+      "path": "pkg/b/b.go",
+      "kind": "go",
+      "imports": [
+        {
+          "old_path": "example.com/pkg/old",
+          "new_path": "example.com/pkg/b"
+        },
+        {
+          "old_path": "example.com/pkg/other",
+          "new_path": "example.com/pkg/new",
+          "new_alias": "new2"
+        }
+      ],
+      "package": {
+        "old_name": "old",
+        "new_name": "b"
+      },
+      "self_imports_removed": [
+        "example.com/pkg/b"
+      ],
+      "imports_cleaned_up": true,
+      "written": true
+    }
+  ],
+  "skipped": [
+    {
+      "path": "pkg/a/a_string.go",
+      "reason": "generated"
+    },
+    {
+      "path": "pkg/a/a_windows.go",
+      "reason": "not built for the selected platforms"
+    },
+    {
+      "path": "pkg/testdata",
+      "dir": true,
+      "reason": "ignored by the go tool"
+    }
+  ],
+  "generated": [
+    {
+      "path": "pkg/a/a.pb.go",
+      "policy": "generate",
+      "directives": [
+        "pkg/a/gen.go:3: //go:generate protoc --go_out=. a.proto"
+      ]
+    },
+    {
+      "path": "pkg/a/a_string.go",
+      "policy": "skip"
+    }
+  ],
+  "errors": [
+    {
+      "path": "pkg/c/c.go",
+      "line": 3,
+      "column": 8,
+      "move": "pkg/old -> pkg/b",
+      "error": "syntax error: expected ';', found 'EOF'"
+    },
+    {
+      "error": "unable to write journal"
+    }
+  ],
+  "stats": {
+    "files_processed": 4,
+    "files_changed": 3,
+    "files_written": 2,
+    "files_skipped": 2,
+    "dirs_skipped": 1,
+    "imports_rewritten": 2,
+    "packages_renamed": 1,
+    "self_imports_removed": 1,
+    "generated_files": 2,
+    "errors": 2
+  }
+}
+`, buf.String())
+}
+
+func TestReportEmpty(t *testing.T) {
+	r := New()
+	r.Finish(nil, nil)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.WriteJSON(&buf))
+	assert.Contains(t, buf.String(), `"files": []`)
+	assert.Contains(t, buf.String(), `"skipped": []`)
+	assert.Contains(t, buf.String(), `"generated": []`)
+	assert.NotContains(t, buf.String(), `"errors": [`)
+}
@@ -0,0 +1,125 @@
+// Package runner runs tasks on a bounded pool of workers, collecting their
+// errors and stopping early when canceled.
+package runner
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// Options control how tasks are run.
+type Options struct {
+	// MaxParallel is the maximum number of tasks run at once, at least one.
+	MaxParallel int
+
+	// FailFast cancels the tasks not yet started on the first error.
+	FailFast bool
+}
+
+// A Task is a unit of work. Tasks should return promptly once the context is
+// done, but needn't abandon work already under way.
+type Task func(ctx context.Context) error
+
+// A Runner runs tasks on a bounded pool of workers. Tasks are started in the
+// order they are submitted, and Go blocks while all of the workers are busy,
+// so the tasks waiting to run never pile up in memory.
+type Runner struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	failFast bool
+	slots    chan struct{}
+	wg       sync.WaitGroup
+
+	mu   sync.Mutex
+	errs []error
+
+	// failed is set once a task fails, to tell the cancellation of a fail-fast
+	// run apart from the cancellation of its parent context
+	failed bool
+}
+
+// New creates a new Runner, whose tasks are canceled along with the given
+// context.
+func New(ctx context.Context, opts Options) *Runner {
+	if opts.MaxParallel < 1 {
+		opts.MaxParallel = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &Runner{
+		ctx:      ctx,
+		cancel:   cancel,
+		failFast: opts.FailFast,
+		slots:    make(chan struct{}, opts.MaxParallel),
+	}
+}
+
+// Context returns the context of the runner's tasks, which is done once the
+// runner has been canceled or has failed fast.
+func (r *Runner) Context() context.Context {
+	return r.ctx
+}
+
+// Go runs the task once a worker is free, returning false without running it
+// if the runner has been canceled.
+func (r *Runner) Go(task Task) bool {
+	// NB(mmihic): Checked first since select picks at random between ready
+	// cases, and a canceled runner shouldn't start any more tasks.
+	if r.ctx.Err() != nil {
+		return false
+	}
+
+	select {
+	case r.slots <- struct{}{}:
+	case <-r.ctx.Done():
+		return false
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer func() {
+			<-r.slots
+			r.wg.Done()
+		}()
+
+		if err := task(r.ctx); err != nil {
+			r.Fail(err)
+		}
+	}()
+
+	return true
+}
+
+// Fail records an error that didn't come from a task, such as an error finding
+// the tasks to run. Like an error from a task, it cancels the remaining tasks
+// if failing fast.
+func (r *Runner) Fail(err error) {
+	r.mu.Lock()
+	r.errs = append(r.errs, err)
+	r.failed = true
+	r.mu.Unlock()
+
+	if r.failFast {
+		r.cancel()
+	}
+}
+
+// Wait waits for the running tasks to finish, returning their errors. If the
+// runner was canceled by its parent context, the cancellation is returned
+// along with the errors, since tasks may have been skipped.
+func (r *Runner) Wait() error {
+	r.wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := multierr.Combine(r.errs...)
+	if ctxErr := r.ctx.Err(); ctxErr != nil && !(r.failFast && r.failed) {
+		err = multierr.Append(err, ctxErr)
+	}
+
+	r.cancel()
+	return err
+}
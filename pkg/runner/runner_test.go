@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+)
+
+func TestRunner_Bounded(t *testing.T) {
+	var running, maxRunning, ran int64
+	r := New(context.Background(), Options{MaxParallel: 3})
+	for i := 0; i < 20; i++ {
+		assert.True(t, r.Go(func(ctx context.Context) error {
+			n := atomic.AddInt64(&running, 1)
+			for {
+				max := atomic.LoadInt64(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt64(&maxRunning, max, n) {
+					break
+				}
+			}
+
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&running, -1)
+			atomic.AddInt64(&ran, 1)
+			return nil
+		}))
+	}
+
+	assert.NoError(t, r.Wait())
+	assert.Equal(t, int64(20), ran)
+	assert.True(t, maxRunning <= 3, "ran %d tasks at once", maxRunning)
+}
+
+func TestRunner_Errors(t *testing.T) {
+	r := New(context.Background(), Options{MaxParallel: 2})
+	for i := 0; i < 4; i++ {
+		i := i
+		r.Go(func(ctx context.Context) error {
+			if i%2 == 1 {
+				return errors.New("odd")
+			}
+			return nil
+		})
+	}
+	r.Fail(errors.New("walk failed"))
+
+	err := r.Wait()
+	assert.Len(t, multierr.Errors(err), 3)
+	assert.Contains(t, err.Error(), "walk failed")
+}
+
+func TestRunner_FailFast(t *testing.T) {
+	var ran int64
+	r := New(context.Background(), Options{MaxParallel: 1, FailFast: true})
+	r.Go(func(ctx context.Context) error {
+		atomic.AddInt64(&ran, 1)
+		return errors.New("first")
+	})
+
+	// The single worker is only freed once the failing task is done, by which
+	// point the runner has been canceled
+	for i := 0; i < 10; i++ {
+		r.Go(func(ctx context.Context) error {
+			atomic.AddInt64(&ran, 1)
+			return nil
+		})
+	}
+
+	assert.EqualError(t, r.Wait(), "first")
+	assert.Equal(t, int64(1), ran)
+}
+
+func TestRunner_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := New(ctx, Options{MaxParallel: 1})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	assert.True(t, r.Go(func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}))
+
+	<-started
+	cancel()
+
+	// In flight tasks finish, but no more are started
+	assert.False(t, r.Go(func(ctx context.Context) error {
+		t.Error("task started after cancellation")
+		return nil
+	}))
+	close(release)
+
+	err := r.Wait()
+	assert.True(t, errors.Is(err, context.Canceled), "unexpected error %v", err)
+}
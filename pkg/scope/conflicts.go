@@ -0,0 +1,49 @@
+package scope
+
+import (
+	"go/ast"
+
+	"github.com/mmihic/go-tools/pkg/imports"
+)
+
+// HasConflict checks whether the given name is bound to a declaration at the
+// top level of the given tree, or at any of the given identifiers. This is
+// used to check whether the identifiers can be renamed to the given name
+// without changing what they refer to, or colliding with another top level name.
+// Declarations for which skip returns true are not considered conflicts.
+func HasConflict(root ast.Node, idents []*ast.Ident, name string, skip func(n ast.Node) bool) bool {
+	return HasConflictNamed(root, nil, idents, name, skip)
+}
+
+// HasConflictNamed is like HasConflict, but declares imports without an explicit
+// name under the name given by the Namer.
+func HasConflictNamed(
+	root ast.Node, namer *imports.Namer, idents []*ast.Ident, name string, skip func(n ast.Node) bool,
+) bool {
+	if skip == nil {
+		skip = func(_ ast.Node) bool { return false } // skip nothing
+	}
+
+	checkAt := map[ast.Node]struct{}{root: {}}
+	for _, ident := range idents {
+		checkAt[ident] = struct{}{}
+	}
+
+	hasConflict := false
+	InspectNamed(root, namer, func(n ast.Node, s *Scope) bool {
+		if hasConflict {
+			return false
+		}
+
+		if _, ok := checkAt[n]; !ok {
+			return true
+		}
+
+		if decl := s.GetDecl(name); decl != nil && !skip(decl) {
+			hasConflict = true
+		}
+		return !hasConflict
+	})
+
+	return hasConflict
+}
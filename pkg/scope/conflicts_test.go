@@ -0,0 +1,122 @@
+package scope
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasConflict(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{
+			name: "no conflict with variable in another function",
+			src: `
+package whatever
+
+import "github.com/src/something/first"
+
+func doIt() { first.Do() }
+
+func doItAgain() {
+	var conflicts = 10
+	println(conflicts)
+}
+`,
+			want: false,
+		},
+		{
+			name: "conflict with variable shadowing use",
+			src: `
+package whatever
+
+import "github.com/src/something/first"
+
+func doIt() {
+	var conflicts = 10
+	first.Do(conflicts)
+}
+`,
+			want: true,
+		},
+		{
+			name: "no conflict with variable declared after use",
+			src: `
+package whatever
+
+import "github.com/src/something/first"
+
+func doIt() {
+	first.Do()
+	conflicts := 10
+	println(conflicts)
+}
+`,
+			want: false,
+		},
+		{
+			name: "conflict with parameter",
+			src: `
+package whatever
+
+import "github.com/src/something/first"
+
+func doIt(conflicts int) { first.Do(conflicts) }
+`,
+			want: true,
+		},
+		{
+			name: "conflict with top level declaration without uses",
+			src: `
+package whatever
+
+import "github.com/src/something/first"
+
+type conflicts int
+`,
+			want: true,
+		},
+		{
+			name: "conflict with other import",
+			src: `
+package whatever
+
+import (
+	"github.com/src/something/first"
+	"github.com/src/other/conflicts"
+)
+`,
+			want: true,
+		},
+		{
+			name: "no conflict with self",
+			src: `
+package whatever
+
+import conflicts "github.com/src/something/first"
+
+func doIt() { conflicts.Do() }
+`,
+			want: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "", tt.src, parser.ParseComments)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			imp := file.Imports[0]
+			refs := References(file, imp)
+			skipSelf := func(n ast.Node) bool { return n == imp }
+			assert.Equal(t, tt.want, HasConflict(file, refs, "conflicts", skipSelf))
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package scope
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+
+	"github.com/mmihic/go-tools/pkg/imports"
+)
+
+// An Index records the declaration that each identifier in a tree binds to,
+// and the identifiers that refer to each declaration.
+//
+// Declarations are the nodes tracked by a Scope: *ast.FuncDecl, *ast.Field
+// (for receivers, parameters and results), *ast.ValueSpec, *ast.TypeSpec,
+// *ast.ImportSpec, *ast.AssignStmt (for short variable declarations) and
+// *ast.RangeStmt. Identifiers that can only be resolved with type information,
+// such as the selector in x.Sel, struct fields and methods, are not bound.
+type Index struct {
+	decls map[*ast.Ident]ast.Node
+	refs  map[ast.Node][]*ast.Ident
+}
+
+// NewIndex builds an index of the identifiers in the given tree. The tree is
+// typically an *ast.File, or an *ast.Package to also resolve references to
+// declarations in other files of the same package.
+func NewIndex(root ast.Node) *Index {
+	return NewIndexNamed(root, nil)
+}
+
+// NewIndexNamed is like NewIndex, but binds references to imports without an
+// explicit name using the name given by the Namer.
+func NewIndexNamed(root ast.Node, namer *imports.Namer) *Index {
+	idx := &Index{
+		decls: map[*ast.Ident]ast.Node{},
+		refs:  map[ast.Node][]*ast.Ident{},
+	}
+
+	names := nameIdents(root)
+	walk(inspector(func(n ast.Node, s *Scope) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		if _, isName := names[ident]; isName {
+			return true
+		}
+
+		if _, bound := idx.decls[ident]; bound {
+			return true
+		}
+
+		if decl := s.GetDecl(ident.Name); decl != nil {
+			idx.decls[ident] = decl
+			idx.refs[decl] = append(idx.refs[decl], ident)
+		}
+		return true
+	}), root, namer, idx.bindDecl)
+
+	for _, refs := range idx.refs {
+		sort.Slice(refs, func(i, j int) bool {
+			return refs[i].Pos() < refs[j].Pos()
+		})
+	}
+
+	return idx
+}
+
+// Resolve returns the declaration to which the identifier binds, or nil if the
+// identifier is predeclared, declared outside of the indexed tree, or cannot be
+// resolved without type information. Identifiers that name a declaration
+// resolve to that declaration.
+func (idx *Index) Resolve(ident *ast.Ident) ast.Node {
+	return idx.decls[ident]
+}
+
+// References returns the identifiers that refer to the given declaration,
+// ordered by position. The identifiers naming the declaration itself are not
+// included. For declarations that introduce multiple names, such as
+// var a, b = 1, 2, the references to all of the names are returned.
+func (idx *Index) References(decl ast.Node) []*ast.Ident {
+	return idx.refs[decl]
+}
+
+// Resolve returns the declaration to which the identifier binds within the
+// given tree.
+func Resolve(root ast.Node, ident *ast.Ident) ast.Node {
+	return NewIndex(root).Resolve(ident)
+}
+
+// References returns the identifiers within the given tree that refer to
+// the given declaration.
+func References(root, decl ast.Node) []*ast.Ident {
+	return NewIndex(root).References(decl)
+}
+
+// bindDecl binds the identifiers naming a declaration to that declaration.
+func (idx *Index) bindDecl(name string, decl ast.Node) {
+	for _, ident := range DeclIdents(decl) {
+		if ident.Name == name {
+			idx.decls[ident] = decl
+		}
+	}
+}
+
+// DeclIdents returns the identifiers named by the given declaration.
+func DeclIdents(decl ast.Node) []*ast.Ident {
+	switch n := decl.(type) {
+	case *ast.FuncDecl:
+		return []*ast.Ident{n.Name}
+	case *ast.Field:
+		return n.Names
+	case *ast.ValueSpec:
+		return n.Names
+	case *ast.TypeSpec:
+		return []*ast.Ident{n.Name}
+	case *ast.ImportSpec:
+		if n.Name != nil {
+			return []*ast.Ident{n.Name}
+		}
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			return exprIdents(n.Lhs...)
+		}
+	case *ast.RangeStmt:
+		if n.Tok == token.DEFINE {
+			return exprIdents(n.Key, n.Value)
+		}
+	}
+
+	return nil
+}
+
+func exprIdents(exprs ...ast.Expr) []*ast.Ident {
+	var idents []*ast.Ident
+	for _, expr := range exprs {
+		if ident, ok := expr.(*ast.Ident); ok {
+			idents = append(idents, ident)
+		}
+	}
+	return idents
+}
+
+// nameIdents returns the identifiers in the tree that name something rather
+// than refer to a declaration: declared names, labels, selectors, struct
+// fields, interface methods and struct literal keys.
+func nameIdents(root ast.Node) map[*ast.Ident]struct{} {
+	names := map[*ast.Ident]struct{}{}
+	add := func(idents ...*ast.Ident) {
+		for _, ident := range idents {
+			if ident != nil {
+				names[ident] = struct{}{}
+			}
+		}
+	}
+
+	ast.Inspect(root, func(nth ast.Node) bool {
+		switch n := nth.(type) {
+		case *ast.File:
+			add(n.Name)
+		case *ast.FuncDecl:
+			add(n.Name)
+		case *ast.Field:
+			add(n.Names...)
+		case *ast.ValueSpec:
+			add(n.Names...)
+		case *ast.TypeSpec:
+			add(n.Name)
+		case *ast.ImportSpec:
+			add(n.Name)
+		case *ast.SelectorExpr:
+			add(n.Sel)
+		case *ast.LabeledStmt:
+			add(n.Label)
+		case *ast.BranchStmt:
+			add(n.Label)
+		case *ast.CompositeLit:
+			if isKeyedByField(n.Type) {
+				for _, elt := range n.Elts {
+					if kv, ok := elt.(*ast.KeyValueExpr); ok {
+						add(exprIdents(kv.Key)...)
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	return names
+}
+
+// isKeyedByField returns true if the keys of a composite literal of the given
+// type may be struct field names. Without type information we can only rule
+// out literal map, slice, and array types.
+func isKeyedByField(typ ast.Expr) bool {
+	switch typ.(type) {
+	case *ast.MapType, *ast.ArrayType:
+		return false
+	}
+	return true
+}
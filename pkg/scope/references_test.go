@@ -0,0 +1,234 @@
+package scope
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferences(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		src  string
+
+		// The occurrence of the identifier named "target" that declares the
+		// target, and the occurrences that should refer to it.
+		declAt int
+		refsAt []int
+	}{
+		{
+			name: "package level function used before declaration",
+			src: `
+package whatever
+
+func doIt() { target() }
+
+func target() {}
+`,
+			declAt: 1,
+			refsAt: []int{0},
+		},
+		{
+			name: "var named after the package it uses",
+			src: `
+package whatever
+
+import "github.com/foo/target"
+
+func doIt() {
+	var target target.Config
+	target.Load()
+}
+`,
+			declAt: 0,
+			refsAt: []int{2},
+		},
+		{
+			name: "import used in var type shadowed by var",
+			src: `
+package whatever
+
+import "github.com/foo/target"
+
+func doIt() {
+	var target target.Config
+	target.Load()
+}
+`,
+			declAt: -1,
+			refsAt: []int{1},
+		},
+		{
+			name: "parameters are scoped to the function body",
+			src: `
+package whatever
+
+import target "github.com/foo/other"
+
+func doIt(target target.Config) {
+	target.Load()
+}
+`,
+			declAt: 1,
+			refsAt: []int{3},
+		},
+		{
+			name: "short variable redeclaration refers to original",
+			src: `
+package whatever
+
+func doIt() {
+	target := 1
+	target, b := 3, 4
+	println(target, b)
+}
+`,
+			declAt: 0,
+			refsAt: []int{1, 2},
+		},
+		{
+			name: "inner scope shadows outer",
+			src: `
+package whatever
+
+var target = 10
+
+func doIt() {
+	println(target)
+	{
+		target := 20
+		println(target)
+	}
+}
+`,
+			declAt: 0,
+			refsAt: []int{1},
+		},
+		{
+			name: "range variables",
+			src: `
+package whatever
+
+func doIt(m map[string]int) {
+	for target := range m {
+		println(target)
+	}
+	println(target)
+}
+`,
+			declAt: 0,
+			refsAt: []int{1},
+		},
+		{
+			name: "fields, selectors and struct literal keys are not references",
+			src: `
+package whatever
+
+type target struct {
+	target int
+}
+
+func doIt() {
+	t := target{target: 1}
+	println(t.target)
+}
+`,
+			declAt: 0,
+			refsAt: []int{2},
+		},
+		{
+			name: "function literal parameters",
+			src: `
+package whatever
+
+var target = 1
+
+var f = func(target int) int {
+	return target
+}
+`,
+			declAt: 1,
+			refsAt: []int{2},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			file, err := parser.ParseFile(fset, "", tt.src, parser.ParseComments)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			targets := findIdents(file, "target")
+			idx := NewIndex(file)
+
+			var decl ast.Node
+			if tt.declAt >= 0 {
+				decl = idx.Resolve(targets[tt.declAt])
+			} else {
+				decl = file.Imports[0]
+			}
+
+			if !assert.NotNil(t, decl) {
+				return
+			}
+
+			var want []*ast.Ident
+			for _, i := range tt.refsAt {
+				want = append(want, targets[i])
+				assert.Equal(t, decl, idx.Resolve(targets[i]))
+			}
+
+			assert.Equal(t, want, idx.References(decl))
+		})
+	}
+}
+
+func TestReferences_AcrossFiles(t *testing.T) {
+	fset := token.NewFileSet()
+	first, err := parser.ParseFile(fset, "first.go", `
+package whatever
+
+func doIt() { target() }
+`, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	second, err := parser.ParseFile(fset, "second.go", `
+package whatever
+
+func target() {}
+`, 0)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	pkg := &ast.Package{
+		Name: "whatever",
+		Files: map[string]*ast.File{
+			"first.go":  first,
+			"second.go": second,
+		},
+	}
+
+	decl := second.Decls[0]
+	refs := References(pkg, decl)
+	if assert.Len(t, refs, 1) {
+		assert.Equal(t, decl, Resolve(pkg, refs[0]))
+		assert.Equal(t, "first.go", fset.Position(refs[0].Pos()).Filename)
+	}
+}
+
+func findIdents(root ast.Node, name string) []*ast.Ident {
+	var idents []*ast.Ident
+	ast.Inspect(root, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == name {
+			idents = append(idents, ident)
+		}
+		return true
+	})
+	return idents
+}
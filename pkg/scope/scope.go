@@ -3,6 +3,8 @@ package scope
 
 import (
 	"go/ast"
+	"go/token"
+	"sort"
 
 	"github.com/mmihic/go-tools/pkg/imports"
 )
@@ -12,11 +14,18 @@ type Visitor interface {
 	Visit(n ast.Node, scope *Scope) Visitor
 }
 
-// Walk visits nodes with scoping information.
+// Walk visits nodes with scoping information. Imports without an explicit name
+// are declared under the name assumed from their path.
 func Walk(v Visitor, n ast.Node) {
+	walk(v, n, nil, nil)
+}
+
+func walk(v Visitor, n ast.Node, namer *imports.Namer, declared func(name string, decl ast.Node)) {
 	scope := &Scope{
-		v:     v,
-		decls: map[string]ast.Node{},
+		v:        v,
+		decls:    map[string]ast.Node{},
+		namer:    namer,
+		declared: declared,
 	}
 
 	ast.Walk(scope, n)
@@ -27,6 +36,12 @@ func Inspect(n ast.Node, f func(ast.Node, *Scope) bool) {
 	Walk(inspector(f), n)
 }
 
+// InspectNamed is like Inspect, but declares imports without an explicit name
+// under the name given by the Namer.
+func InspectNamed(n ast.Node, namer *imports.Namer, f func(ast.Node, *Scope) bool) {
+	walk(inspector(f), n, namer, nil)
+}
+
 type inspector func(ast.Node, *Scope) bool
 
 func (f inspector) Visit(node ast.Node, scope *Scope) Visitor {
@@ -38,9 +53,11 @@ func (f inspector) Visit(node ast.Node, scope *Scope) Visitor {
 
 // Scope tracks declarations in scope.
 type Scope struct {
-	parent *Scope
-	decls  map[string]ast.Node
-	v      Visitor
+	parent   *Scope
+	decls    map[string]ast.Node
+	v        Visitor
+	namer    *imports.Namer
+	declared func(name string, decl ast.Node)
 }
 
 // HasDecl returns true if the a decl is in scope.
@@ -77,77 +94,294 @@ func (s *Scope) Decls() map[string]ast.Node {
 }
 
 func (s *Scope) addDecl(name string, n ast.Node) {
+	if name == "_" || name == "." {
+		return
+	}
+
 	s.decls[name] = n
+	if s.declared != nil {
+		s.declared(name, n)
+	}
+}
+
+func (s *Scope) addFieldDecls(fields *ast.FieldList) {
+	if fields == nil {
+		return
+	}
+
+	for _, f := range fields.List {
+		for _, nm := range f.Names {
+			s.addDecl(nm.Name, f)
+		}
+	}
+}
+
+// addTopLevelDecls hoists the package level declarations in a file, since these
+// are in scope throughout the package regardless of the order in which they appear.
+func (s *Scope) addTopLevelDecls(f *ast.File) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name != "init" {
+				s.addDecl(d.Name.Name, d)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					s.addDecl(sp.Name.Name, sp)
+				case *ast.ValueSpec:
+					for _, nm := range sp.Names {
+						s.addDecl(nm.Name, sp)
+					}
+				}
+			}
+		}
+	}
 }
 
 func (s *Scope) enter() *Scope {
 	return &Scope{
-		parent: s,
-		decls:  map[string]ast.Node{},
-		v:      s.v,
+		parent:   s,
+		decls:    map[string]ast.Node{},
+		v:        s.v,
+		namer:    s.namer,
+		declared: s.declared,
 	}
 }
 
 func (s *Scope) withVisitor(visitor Visitor) *Scope {
 	return &Scope{
-		parent: s.parent,
-		decls:  s.decls,
-		v:      visitor,
+		parent:   s.parent,
+		decls:    s.decls,
+		v:        visitor,
+		namer:    s.namer,
+		declared: s.declared,
 	}
 }
 
-// Walk visits a node, adding declarations or pushing a new block onto the scope.
+// Visit visits a node, adding declarations or pushing a new block onto the scope.
 func (s *Scope) Visit(nth ast.Node) ast.Visitor {
-	ret := s.visit(nth)
-	visitor := ret.v.Visit(nth, ret)
-	if visitor == nil {
+	if nth == nil {
+		s.v.Visit(nil, s)
 		return nil
 	}
 
-	return ret.withVisitor(visitor)
-}
-
-func (s *Scope) visit(nth ast.Node) *Scope {
 	switch n := nth.(type) {
+	case *ast.Package:
+		s.visitPackage(n)
+		return nil
+	case *ast.File:
+		s.addTopLevelDecls(n)
+		fileScope := s.enter()
+		for _, imp := range n.Imports {
+			fileScope.addDecl(s.namer.Name(imp), imp)
+		}
+		return fileScope.visitNode(n)
 	case *ast.FuncDecl:
+		s.visitFuncDecl(n)
+		return nil
+	case *ast.FuncLit:
+		s.visitFuncLit(n)
+		return nil
+	case *ast.BlockStmt, *ast.IfStmt, *ast.ForStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt,
+		*ast.SelectStmt, *ast.CaseClause, *ast.CommClause:
+		return s.enter().visitNode(n)
+	case *ast.RangeStmt:
+		s.enter().visitRangeStmt(n)
+		return nil
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			s.visitDefine(n)
+			return nil
+		}
+	case *ast.ValueSpec:
+		s.visitValueSpec(n)
+		return nil
+	case *ast.TypeSpec:
+		// NB(mmihic): Types can refer to themselves, so the name is in scope
+		// while we visit the type definition.
 		s.addDecl(n.Name.Name, n)
-		return s.enter()
-	case *ast.FuncType:
-		if n.Params != nil {
-			for _, f := range n.Params.List {
-				for _, nm := range f.Names {
-					s.addDecl(nm.Name, f)
-				}
-			}
+		if n.TypeParams != nil {
+			inner := s.enter()
+			inner.addFieldDecls(n.TypeParams)
+			return inner.visitNode(n)
 		}
+	case *ast.ImportSpec:
+		s.addDecl(s.namer.Name(n), n)
+	}
+
+	return s.visitNode(nth)
+}
+
+// visitNode calls the visitor for the given node, returning the ast.Visitor to
+// use for the node's children.
+func (s *Scope) visitNode(n ast.Node) ast.Visitor {
+	v := s.v.Visit(n, s)
+	if v == nil {
+		return nil
+	}
+
+	return s.withVisitor(v)
+}
+
+// visitChildren calls the visitor for the given node, and if the visitor wants to
+// descend into the node calls walkChildren with a scope using the returned visitor.
+func (s *Scope) visitChildren(n ast.Node, walkChildren func(child *Scope)) bool {
+	v := s.v.Visit(n, s)
+	if v == nil {
+		return false
+	}
+
+	child := s.withVisitor(v)
+	walkChildren(child)
+	v.Visit(nil, child)
+	return true
+}
 
-		if n.Results != nil {
-			for _, f := range n.Results.List {
-				for _, nm := range f.Names {
-					s.addDecl(nm.Name, f)
+func (s *Scope) walk(nodes ...ast.Node) {
+	for _, n := range nodes {
+		if !isNil(n) {
+			ast.Walk(s, n)
+		}
+	}
+}
+
+func (s *Scope) walkExprs(exprs []ast.Expr) {
+	for _, expr := range exprs {
+		s.walk(expr)
+	}
+}
+
+func (s *Scope) visitPackage(pkg *ast.Package) {
+	fnames := make([]string, 0, len(pkg.Files))
+	for fname, f := range pkg.Files {
+		s.addTopLevelDecls(f)
+		fnames = append(fnames, fname)
+	}
+
+	// NB(mmihic): Walk files in a stable order so that visitors see the same
+	// sequence of nodes on every run.
+	sort.Strings(fnames)
+	s.visitChildren(pkg, func(child *Scope) {
+		for _, fname := range fnames {
+			child.walk(pkg.Files[fname])
+		}
+	})
+}
+
+func (s *Scope) visitFuncDecl(fn *ast.FuncDecl) {
+	if fn.Recv == nil && fn.Name.Name != "init" {
+		s.addDecl(fn.Name.Name, fn)
+	}
+
+	inner := s.enter()
+	inner.addFieldDecls(fn.Type.TypeParams)
+	s.visitChildren(fn, func(child *Scope) {
+		child.walk(fn.Doc, fn.Name)
+
+		// NB(mmihic): Receivers, parameters, and results are only in scope within
+		// the function body, so the signature is walked before they are declared.
+		sig := inner.withVisitor(child.v)
+		sig.walk(fn.Recv, fn.Type)
+		sig.addFieldDecls(fn.Recv)
+		sig.addFieldDecls(fn.Type.Params)
+		sig.addFieldDecls(fn.Type.Results)
+		sig.walk(fn.Body)
+	})
+}
+
+func (s *Scope) visitFuncLit(fn *ast.FuncLit) {
+	inner := s.enter()
+	s.visitChildren(fn, func(child *Scope) {
+		sig := inner.withVisitor(child.v)
+		sig.walk(fn.Type)
+		sig.addFieldDecls(fn.Type.Params)
+		sig.addFieldDecls(fn.Type.Results)
+		sig.walk(fn.Body)
+	})
+}
+
+func (s *Scope) visitRangeStmt(n *ast.RangeStmt) {
+	s.visitChildren(n, func(child *Scope) {
+		child.walk(n.X)
+		if n.Tok == token.DEFINE {
+			for _, expr := range []ast.Expr{n.Key, n.Value} {
+				if ident, ok := expr.(*ast.Ident); ok {
+					child.addDecl(ident.Name, n)
 				}
 			}
 		}
-	case *ast.BlockStmt:
-		return s.enter()
-	case *ast.ValueSpec:
-		for _, nm := range n.Names {
-			s.addDecl(nm.Name, n)
-		}
-	case *ast.TypeSpec:
-		s.addDecl(n.Name.Name, n)
-	case *ast.ImportSpec:
-		s.addDecl(imports.Name(n), n)
-	case *ast.AssignStmt:
+		child.walk(n.Key, n.Value, n.Body)
+	})
+}
+
+// visitDefine visits a short variable declaration. Names on the left hand side
+// are only in scope after the statement, and names already declared in the
+// same scope are re-assigned rather than declared.
+func (s *Scope) visitDefine(n *ast.AssignStmt) {
+	declare := func() {
 		for _, lhs := range n.Lhs {
 			ident, ok := lhs.(*ast.Ident)
 			if !ok {
 				continue
 			}
 
-			s.addDecl(ident.Name, n)
+			if _, exists := s.decls[ident.Name]; !exists {
+				s.addDecl(ident.Name, n)
+			}
 		}
 	}
 
-	return s
+	if !s.visitChildren(n, func(child *Scope) {
+		child.walkExprs(n.Rhs)
+		declare()
+		child.walkExprs(n.Lhs)
+	}) {
+		declare()
+	}
+}
+
+// visitValueSpec visits a const or var declaration. As with short variable
+// declarations, names are only in scope after the spec.
+func (s *Scope) visitValueSpec(n *ast.ValueSpec) {
+	declare := func() {
+		for _, nm := range n.Names {
+			s.addDecl(nm.Name, n)
+		}
+	}
+
+	if !s.visitChildren(n, func(child *Scope) {
+		child.walk(n.Doc, n.Type)
+		child.walkExprs(n.Values)
+		declare()
+		for _, nm := range n.Names {
+			child.walk(nm)
+		}
+		child.walk(n.Comment)
+	}) {
+		declare()
+	}
+}
+
+// isNil checks whether the node is nil, including typed nil pointers
+// stored in the interface.
+func isNil(n ast.Node) bool {
+	switch nn := n.(type) {
+	case nil:
+		return true
+	case *ast.CommentGroup:
+		return nn == nil
+	case *ast.Ident:
+		return nn == nil
+	case *ast.FieldList:
+		return nn == nil
+	case *ast.FuncType:
+		return nn == nil
+	case *ast.BlockStmt:
+		return nn == nil
+	case *ast.File:
+		return nn == nil
+	}
+	return false
 }
@@ -0,0 +1,302 @@
+// Package textedit computes and applies minimal text edits to Go source.
+package textedit
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"sort"
+)
+
+// An Edit replaces the bytes in the range [Pos, End) of a source file with
+// NewText. Insertions have Pos == End, deletions have an empty NewText.
+type Edit struct {
+	Pos     int    `json:"pos"`
+	End     int    `json:"end"`
+	NewText []byte `json:"new_text"`
+}
+
+// Apply applies the given edits to the source, returning the edited source.
+// The edits may be in any order, but must not overlap.
+func Apply(src []byte, edits []Edit) ([]byte, error) {
+	sorted := append([]Edit{}, edits...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Pos < sorted[j].Pos
+	})
+
+	var (
+		buf  bytes.Buffer
+		last int
+	)
+
+	for _, e := range sorted {
+		if e.Pos < last || e.End < e.Pos || e.End > len(src) {
+			return nil, fmt.Errorf("invalid edit [%d, %d) with source of length %d", e.Pos, e.End, len(src))
+		}
+
+		buf.Write(src[last:e.Pos])
+		buf.Write(e.NewText)
+		last = e.End
+	}
+
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// Diff computes the edits that transform the original source into the
+// updated source. Both must be valid Go source. The sources are compared
+// token by token, so differences in layout alone, such as a reprinted file
+// realigning comments, do not produce edits; only the tokens that actually
+// changed are replaced, taking their layout from the updated source.
+func Diff(orig, updated []byte) ([]Edit, error) {
+	origToks, err := tokenize(orig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to tokenize original source: %v", err)
+	}
+
+	updatedToks, err := tokenize(updated)
+	if err != nil {
+		return nil, fmt.Errorf("unable to tokenize updated source: %v", err)
+	}
+
+	// NB(mmihic): The EOF tokens are left out of the comparison, so that every
+	// hunk is followed by a token common to both sources.
+	var edits []Edit
+	for _, h := range diffTokens(origToks[:len(origToks)-1], updatedToks[:len(updatedToks)-1]) {
+		edits = append(edits, h.edit(origToks, updatedToks, updated))
+	}
+
+	return edits, nil
+}
+
+// A srcToken is a token along with its byte range in the source.
+type srcToken struct {
+	tok      token.Token
+	lit      string
+	pos, end int
+}
+
+func (t srcToken) equal(other srcToken) bool {
+	if t.tok != other.tok {
+		return false
+	}
+
+	// NB(mmihic): Explicit and implicit semicolons are interchangeable, and
+	// operators and keywords have no literal.
+	switch t.tok {
+	case token.SEMICOLON:
+		return true
+	case token.IDENT, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING, token.COMMENT:
+		return t.lit == other.lit
+	default:
+		return true
+	}
+}
+
+// tokenize splits the source into tokens, including comments. The final
+// token is always EOF.
+func tokenize(src []byte) ([]srcToken, error) {
+	var (
+		s    scanner.Scanner
+		errs scanner.ErrorList
+		toks []srcToken
+	)
+
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	s.Init(file, src, func(pos token.Position, msg string) {
+		errs.Add(pos, msg)
+	}, scanner.ScanComments)
+
+	for {
+		pos, tok, lit := s.Scan()
+		offset := file.Offset(pos)
+		toks = append(toks, srcToken{
+			tok: tok,
+			lit: lit,
+			pos: offset,
+			end: tokenEnd(src, offset, tok, lit),
+		})
+
+		if tok == token.EOF {
+			break
+		}
+	}
+
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+
+	return toks, nil
+}
+
+// tokenEnd returns the offset at which the token starting at the given offset
+// ends. The end of comments and raw strings is found in the source, since the
+// scanner strips carriage returns from their literal.
+func tokenEnd(src []byte, offset int, tok token.Token, lit string) int {
+	switch {
+	case tok == token.EOF:
+		return offset
+	case tok == token.SEMICOLON && lit == "\n":
+		// Implicit semicolons at the end of the file have no text
+		if offset < len(src) && src[offset] == '\n' {
+			return offset + 1
+		}
+		return offset
+	case tok == token.COMMENT && bytes.HasPrefix(src[offset:], []byte("/*")):
+		if end := bytes.Index(src[offset+2:], []byte("*/")); end >= 0 {
+			return offset + 2 + end + 2
+		}
+		return len(src)
+	case tok == token.COMMENT:
+		if end := bytes.IndexByte(src[offset:], '\n'); end >= 0 {
+			return offset + len(bytes.TrimRight(src[offset:offset+end], "\r"))
+		}
+		return len(src)
+	case tok == token.STRING && len(lit) != 0 && lit[0] == '`':
+		if end := bytes.IndexByte(src[offset+1:], '`'); end >= 0 {
+			return offset + 1 + end + 1
+		}
+		return len(src)
+	case lit != "":
+		return offset + len(lit)
+	default:
+		return offset + len(tok.String())
+	}
+}
+
+// A hunk replaces the original tokens [i1, i2) with the updated tokens [j1, j2).
+type hunk struct {
+	i1, i2 int
+	j1, j2 int
+}
+
+// edit converts the hunk into an edit of the original source. The hunk is
+// always followed by a token common to both sources, if only the EOF.
+func (h hunk) edit(orig, updated []srcToken, updatedSrc []byte) Edit {
+	if h.i1 == h.i2 || h.j1 == h.j2 {
+		// NB(mmihic): Pure insertions and deletions replace the layout between the
+		// surrounding tokens as well, so that removing a line removes the whole
+		// line and inserted tokens are indented and separated as in the update.
+		e := Edit{End: orig[h.i2].pos}
+		if h.i1 != 0 {
+			e.Pos = orig[h.i1-1].end
+		}
+
+		var updatedPos int
+		if h.j1 != 0 {
+			updatedPos = updated[h.j1-1].end
+		}
+
+		e.NewText = updatedSrc[updatedPos:updated[h.j2].pos]
+		return e
+	}
+
+	return Edit{
+		Pos:     orig[h.i1].pos,
+		End:     orig[h.i2-1].end,
+		NewText: updatedSrc[updated[h.j1].pos:updated[h.j2-1].end],
+	}
+}
+
+// maxEditDistance bounds the work done comparing sources; sources differing by
+// more tokens than this are replaced wholesale.
+const maxEditDistance = 1000
+
+// diffTokens computes the hunks that differ between the two token sequences,
+// using the Myers difference algorithm.
+func diffTokens(a, b []srcToken) []hunk {
+	n, m := len(a), len(b)
+	maxD := n + m
+	if maxD > maxEditDistance {
+		maxD = maxEditDistance
+	}
+
+	// Forward pass, keeping the furthest reaching path along each diagonal
+	// for every edit distance
+	var (
+		offset = maxD + 1
+		v      = make([]int, 2*maxD+3)
+		trace  [][]int
+		found  bool
+	)
+
+	for d := 0; d <= maxD && !found; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x].equal(b[y]) {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+
+		trace = append(trace, append([]int{}, v...))
+	}
+
+	if !found {
+		return []hunk{{i1: 0, i2: n, j1: 0, j2: m}}
+	}
+
+	// Backtrack to find the matching tokens, from the end
+	var (
+		matches [][2]int
+		x, y    = n, m
+	)
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prev, k := trace[d-1], x-y
+
+			prevK := k - 1
+			if k == -d || (k != d && prev[offset+k-1] < prev[offset+k+1]) {
+				prevK = k + 1
+			}
+
+			prevX = prev[offset+prevK]
+			prevY = prevX - prevK
+		}
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, [2]int{x, y})
+		}
+
+		x, y = prevX, prevY
+	}
+
+	// Walk the matches forward, collecting the gaps between them
+	var (
+		hunks  []hunk
+		ai, bi int
+	)
+
+	for i := len(matches) - 1; i >= 0; i-- {
+		mx, my := matches[i][0], matches[i][1]
+		if mx != ai || my != bi {
+			hunks = append(hunks, hunk{i1: ai, i2: mx, j1: bi, j2: my})
+		}
+		ai, bi = mx+1, my+1
+	}
+
+	if ai != n || bi != m {
+		hunks = append(hunks, hunk{i1: ai, i2: n, j1: bi, j2: m})
+	}
+
+	return hunks
+}
@@ -0,0 +1,236 @@
+package textedit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		orig      string
+		updated   string
+		want      string
+		wantEdits int
+	}{
+		{
+			name: "keeps original layout of unchanged code",
+			orig: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/first"
+)
+
+type Config struct {
+	A    int // a
+	Long string   // not aligned
+}
+
+func DoSomething() string   { return first.DoSomething() }
+`,
+			updated: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/other"
+)
+
+type Config struct {
+	A    int    // a
+	Long string // not aligned
+}
+
+func DoSomething() string { return other.DoSomething() }
+`,
+			want: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/other"
+)
+
+type Config struct {
+	A    int // a
+	Long string   // not aligned
+}
+
+func DoSomething() string   { return other.DoSomething() }
+`,
+			wantEdits: 2,
+		},
+		{
+			name: "adds and removes aliases",
+			orig: `
+package main
+
+import (
+	first "github.com/mmihic/go-tools/pkg/first"
+	"github.com/mmihic/go-tools/pkg/second"
+)
+
+var x = first.X + second.Y
+`,
+			updated: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/first"
+	other "github.com/mmihic/go-tools/pkg/second"
+)
+
+var x = first.X + other.Y
+`,
+			want: `
+package main
+
+import (
+	"github.com/mmihic/go-tools/pkg/first"
+	other "github.com/mmihic/go-tools/pkg/second"
+)
+
+var x = first.X + other.Y
+`,
+			wantEdits: 3,
+		},
+		{
+			name: "removes lines",
+			orig: `
+package main
+
+import (
+	"fmt"
+	"github.com/mmihic/go-tools/pkg/first"
+	"os"
+)
+
+func main() {
+	fmt.Println(os.Args)   // args
+	first.DoSomething()
+}
+`,
+			updated: `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println(os.Args) // args
+	DoSomething()
+}
+`,
+			want: `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	fmt.Println(os.Args)   // args
+	DoSomething()
+}
+`,
+			wantEdits: 2,
+		},
+		{
+			name: "inserts lines",
+			orig: `
+package main
+
+import (
+	"fmt"
+)
+
+var x = fmt.Sprint()   // x
+`,
+			updated: `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var x = fmt.Sprint(os.Args) // x
+`,
+			want: `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var x = fmt.Sprint(os.Args)   // x
+`,
+			wantEdits: 2,
+		},
+		{
+			name: "rewrites comments",
+			orig: `
+// Package first does things.
+package first
+
+/* Block comment */
+var X = 10
+`,
+			updated: `
+// Package other does things.
+package other
+
+/* Block comment */
+var X = 10
+`,
+			want: `
+// Package other does things.
+package other
+
+/* Block comment */
+var X = 10
+`,
+			wantEdits: 2,
+		},
+		{
+			name:      "no changes",
+			orig:      "package main\n\nvar x   = 10\n",
+			updated:   "package main\n\nvar x = 10\n",
+			want:      "package main\n\nvar x   = 10\n",
+			wantEdits: 0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := []byte(strings.TrimLeft(tt.orig, "\n"))
+			edits, err := Diff(orig, []byte(strings.TrimLeft(tt.updated, "\n")))
+			require.NoError(t, err)
+			assert.Len(t, edits, tt.wantEdits)
+
+			results, err := Apply(orig, edits)
+			require.NoError(t, err)
+			assert.Equal(t, strings.TrimLeft(tt.want, "\n"), string(results))
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	results, err := Apply([]byte("hello there world"), []Edit{
+		{Pos: 12, End: 17, NewText: []byte("everyone")},
+		{Pos: 0, End: 5, NewText: []byte("goodbye")},
+		{Pos: 5, End: 5, NewText: []byte(",")},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "goodbye, there everyone", string(results))
+
+	_, err = Apply([]byte("hello there world"), []Edit{
+		{Pos: 0, End: 7},
+		{Pos: 5, End: 10},
+	})
+	assert.Error(t, err)
+}